@@ -11,58 +11,44 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 func videoHandler(w *response.Writer, req *request.Request) {
 	wd, err := os.Getwd()
 	if err != nil {
-		_ = w.WriteStatusLine(response.StatusInternalServerError)
-		h := response.GetDefaultHeaders(len("Video not found"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Video not found"))
+		w.SetStatus(response.StatusInternalServerError)
+		_, _ = w.Write([]byte("Video not found"))
 		return
 	}
 	videoPath := filepath.Join(wd, "assets", "vim.mp4")
 
 	videoData, err := os.ReadFile(videoPath)
 	if err != nil {
-		_ = w.WriteStatusLine(response.StatusInternalServerError)
-		h := response.GetDefaultHeaders(len("Video not found"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Video not found"))
+		w.SetStatus(response.StatusInternalServerError)
+		_, _ = w.Write([]byte("Video not found"))
 		return
 	}
 
-	_ = w.WriteStatusLine(response.StatusOK)
-
-	h := headers.NewHeaders()
-	h["Content-Length"] = fmt.Sprintf("%d", len(videoData))
-	h["Content-Type"] = "video/mp4"
-	h["Connection"] = "close"
-	_ = w.WriteHeaders(h)
-
-	_, _ = w.WriteBody(videoData)
+	w.Header().Set("Content-Length", strconv.Itoa(len(videoData)))
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Connection", "close")
+	_, _ = w.Write(videoData)
 }
 
 func proxyHandler(w *response.Writer, req *request.Request) {
-	if !strings.HasPrefix(req.RequestLine.RequestTarget, "/httpbin/") {
-		_ = w.WriteStatusLine(response.StatusBadRequest)
-		h := response.GetDefaultHeaders(len("Bad Request"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Bad Request"))
-		return
+	path := strings.TrimPrefix(req.Target.RawPath, "/httpbin")
+	if req.Target.RawQuery != "" {
+		path += "?" + req.Target.RawQuery
 	}
-
-	path := strings.TrimPrefix(req.RequestLine.RequestTarget, "/httpbin")
 	targetURL := "https://httpbin.org" + path
 
 	resp, err := http.Get(targetURL)
 	if err != nil {
-		_ = w.WriteStatusLine(response.StatusInternalServerError)
-		h := response.GetDefaultHeaders(len("Internal Error"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Internal Error"))
+		w.SetStatus(response.StatusInternalServerError)
+		_, _ = w.Write([]byte("Internal Error"))
 		return
 	}
 	defer func() {
@@ -71,17 +57,25 @@ func proxyHandler(w *response.Writer, req *request.Request) {
 		}
 	}()
 
+	w.SetReason(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "))
 	_ = w.WriteStatusLine(response.StatusCode(resp.StatusCode))
 
 	h := headers.NewHeaders()
-	for k, v := range resp.Header {
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
 		if strings.ToLower(k) == "content-length" {
 			continue
 		}
-		h[k] = strings.Join(v, ", ")
+		for _, v := range resp.Header[k] {
+			h.Add(k, v)
+		}
 	}
-	h["Transfer-Encoding"] = "chunked"
-	h["Trailer"] = "X-Content-SHA256, X-Content-Length"
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "X-Content-SHA256, X-Content-Length")
 	_ = w.WriteHeaders(h)
 
 	var bodyBuffer []byte
@@ -109,7 +103,7 @@ func proxyHandler(w *response.Writer, req *request.Request) {
 	contentLength := len(bodyBuffer)
 
 	trailers := headers.NewHeaders()
-	trailers["X-Content-SHA256"] = hashHex
-	trailers["X-Content-Length"] = fmt.Sprintf("%d", contentLength)
+	trailers.Set("X-Content-SHA256", hashHex)
+	trailers.Set("X-Content-Length", fmt.Sprintf("%d", contentLength))
 	_ = w.WriteTrailers(trailers)
 }
@@ -1,29 +1,168 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"httpfromtcp/internal/certs"
+	"httpfromtcp/internal/middleware"
 	"httpfromtcp/internal/server"
+	"httpfromtcp/internal/socket"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
-const port = 42069
+const (
+	defaultListen   = "localhost:42069"
+	unixPrefix      = "unix:"
+	shutdownTimeout = 30 * time.Second
+	certWatchPeriod = 10 * time.Second
+	handoffTimeout  = 10 * time.Second
+)
 
 func main() {
-	server, err := server.Serve(port, router)
+	listen := flag.String("listen", defaultListen, "comma-separated addresses to listen on: host:port, [ipv6]:port or unix:/path")
+	certFiles := flag.String("tls-cert", "", "comma-separated certificate files; serves HTTPS when set")
+	keyFiles := flag.String("tls-key", "", "comma-separated key files, one per certificate")
+	clientAuth := flag.String("tls-client-auth", "none", "client certificate policy: none, request or require-and-verify")
+	clientCAFiles := flag.String("tls-client-ca", "", "comma-separated CA files that client certificates must chain to")
+	flag.Parse()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	opts := []server.Option{
+		server.WithMiddleware(middleware.Recover(), middleware.RequestID(), middleware.Logger(nil)),
+	}
+	if *certFiles != "" || *keyFiles != "" {
+		pairs, err := keyPairs(*certFiles, *keyFiles)
+		if err != nil {
+			log.Fatalf("Error reading TLS flags: %v", err)
+		}
+		store, err := certs.New(pairs...)
+		if err != nil {
+			log.Fatalf("Error loading certificates: %v", err)
+		}
+		tlsConfig, err := clientAuthConfig(store, *clientAuth, *clientCAFiles)
+		if err != nil {
+			log.Fatalf("Error configuring client authentication: %v", err)
+		}
+		store.ReloadOnSignal(ctx, syscall.SIGHUP)
+		store.Watch(ctx, certWatchPeriod)
+		opts = append(opts, server.WithTLS(tlsConfig))
+	}
+
+	listeners, ready, err := openListeners(*listen)
+	if err != nil {
+		log.Fatalf("Error opening listeners: %v", err)
+	}
+	server, err := server.ServeListener(listeners[0], newRouter().ServeHTTP, opts...)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
-	defer func() {
-		if err := server.Close(); err != nil {
-			log.Printf("Error closing server: %v", err)
+	for _, l := range listeners[1:] {
+		if err := server.AddListener(l); err != nil {
+			log.Fatalf("Error listening on %v: %v", l.Addr(), err)
 		}
-	}()
-	log.Println("Server started on port", port)
+	}
+	for _, addr := range server.Addrs() {
+		log.Printf("Server listening on %s %s", addr.Network(), addr)
+	}
+	if err := ready(); err != nil {
+		log.Printf("Error reporting readiness to the previous process: %v", err)
+	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+	for sig := range sigChan {
+		if sig != syscall.SIGUSR2 {
+			break
+		}
+		// Hand the listeners to a new binary and drain once it serves them.
+		process, err := socket.Restart(listeners, handoffTimeout)
+		if err != nil {
+			log.Printf("Error restarting: %v", err)
+			continue
+		}
+		log.Printf("Handed listeners to pid %d", process.Pid)
+		break
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
 	log.Println("Server gracefully stopped")
 }
+
+func keyPairs(certFiles, keyFiles string) ([]certs.KeyPair, error) {
+	certList := strings.Split(certFiles, ",")
+	keyList := strings.Split(keyFiles, ",")
+	if len(certList) != len(keyList) {
+		return nil, fmt.Errorf("%d certificate files but %d key files", len(certList), len(keyList))
+	}
+	pairs := make([]certs.KeyPair, len(certList))
+	for i := range certList {
+		pairs[i] = certs.KeyPair{CertFile: strings.TrimSpace(certList[i]), KeyFile: strings.TrimSpace(keyList[i])}
+	}
+	return pairs, nil
+}
+
+func clientAuthConfig(store *certs.Store, policyName, caFiles string) (*tls.Config, error) {
+	policy, err := certs.ParseClientAuth(policyName)
+	if err != nil {
+		return nil, err
+	}
+	if policy == certs.ClientAuthNone {
+		return store.TLSConfig()
+	}
+	if caFiles == "" {
+		return nil, fmt.Errorf("client auth policy %v needs -tls-client-ca", policy)
+	}
+	roots, err := certs.LoadCAPool(strings.Split(caFiles, ",")...)
+	if err != nil {
+		return nil, err
+	}
+	return store.TLSConfig(certs.WithClientAuth(policy, roots))
+}
+
+// openListeners prefers listeners handed over by a previous process, then
+// those from systemd socket activation, and opens the -listen addresses only
+// when neither is present.
+func openListeners(addresses string) ([]net.Listener, func() error, error) {
+	listeners, ready, err := socket.Inherited()
+	if err != nil || len(listeners) > 0 {
+		return listeners, ready, err
+	}
+	listeners, err = socket.Activated()
+	if err != nil || len(listeners) > 0 {
+		return listeners, ready, err
+	}
+	for _, addr := range strings.Split(addresses, ",") {
+		l, err := net.Listen(listenAddress(addr))
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, ready, nil
+}
+
+// listenAddress maps a -listen entry to the arguments of net.Listen.
+func listenAddress(addr string) (network, address string) {
+	addr = strings.TrimSpace(addr)
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
@@ -3,26 +3,18 @@ package main
 import (
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
-	"strings"
+	"httpfromtcp/internal/router"
 )
 
-func router(w *response.Writer, req *request.Request) {
-	path := req.RequestLine.RequestTarget
+func newRouter() *router.Router {
+	r := router.New()
+	r.GET("/", indexHandler)
+	r.GET("/video", videoHandler)
+	r.GET("/httpbin/*path", proxyHandler)
+	return r
+}
 
-	switch {
-	case path == "/":
-		_ = w.WriteStatusLine(response.StatusOK)
-		h := response.GetDefaultHeaders(len("Welcome to server 42069\n"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Welcome to server 42069\n"))
-	case path == "/video":
-		videoHandler(w, req)
-	case strings.HasPrefix(path, "/httpbin/"):
-		proxyHandler(w, req)
-	default:
-		_ = w.WriteStatusLine(response.StatusBadRequest)
-		h := response.GetDefaultHeaders(len("Not Found"))
-		_ = w.WriteHeaders(h)
-		_, _ = w.WriteBody([]byte("Not Found"))
-	}
+func indexHandler(w *response.Writer, req *request.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("Welcome to server 42069\n"))
 }
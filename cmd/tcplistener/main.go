@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"httpfromtcp/internal/request"
+	"io"
 	"net"
 )
 
@@ -34,10 +35,15 @@ func main() {
 		fmt.Printf("- Version: %v\n", r.RequestLine.HttpVersion)
 
 		fmt.Printf("Headers:\n")
-		for key, value := range r.Headers {
-			fmt.Printf("- %s: %s\n", key, value)
+		for _, field := range r.Headers.Fields() {
+			fmt.Printf("- %s: %s\n", field.Name, field.Value)
 		}
 		fmt.Printf("Body:\n")
-		fmt.Print(string(r.Body))
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			fmt.Printf("Error reading body: %v\n", err)
+			continue
+		}
+		fmt.Print(string(body))
 	}
 }
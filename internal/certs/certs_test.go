@@ -0,0 +1,358 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a self-signed certificate for names into dir and
+// returns the key pair naming its files.
+func writeKeyPair(t *testing.T, dir, file string, names ...string) KeyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	pair := KeyPair{
+		CertFile: filepath.Join(dir, file+".crt"),
+		KeyFile:  filepath.Join(dir, file+".key"),
+	}
+	require.NoError(t, os.WriteFile(pair.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(pair.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return pair
+}
+
+func serialFor(t *testing.T, s *Store, serverName string) *big.Int {
+	t.Helper()
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	return cert.Leaf.SerialNumber
+}
+
+func leafSerial(t *testing.T, pair KeyPair) *big.Int {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber
+}
+
+func TestNew_NoPairs(t *testing.T) {
+	_, err := New()
+	assert.Error(t, err)
+}
+
+func TestNew_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := New(KeyPair{CertFile: filepath.Join(dir, "a.crt"), KeyFile: filepath.Join(dir, "a.key")})
+	assert.Error(t, err)
+}
+
+func TestGetCertificate_SNI(t *testing.T) {
+	dir := t.TempDir()
+	fallback := writeKeyPair(t, dir, "default", "default.test")
+	exact := writeKeyPair(t, dir, "exact", "www.example.test")
+	wildcard := writeKeyPair(t, dir, "wildcard", "*.example.test")
+
+	s, err := New(fallback, exact, wildcard)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		serverName string
+		expected   KeyPair
+	}{
+		{"exact name", "www.example.test", exact},
+		{"case and trailing dot", "WWW.Example.Test.", exact},
+		{"wildcard", "api.example.test", wildcard},
+		{"wildcard covers one label only", "a.b.example.test", fallback},
+		{"wildcard does not cover the apex", "example.test", fallback},
+		{"no SNI", "", fallback},
+		{"unknown name", "other.test", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, leafSerial(t, tt.expected), serialFor(t, s, tt.serverName))
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeKeyPair(t, dir, "site", "example.test")
+	s, err := New(pair)
+	require.NoError(t, err)
+	before := serialFor(t, s, "example.test")
+
+	writeKeyPair(t, dir, "site", "example.test")
+	require.NoError(t, s.Reload())
+	after := serialFor(t, s, "example.test")
+	assert.NotEqual(t, before, after)
+	assert.Equal(t, leafSerial(t, pair), after)
+}
+
+func TestReload_FailureKeepsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeKeyPair(t, dir, "site", "example.test")
+	s, err := New(pair)
+	require.NoError(t, err)
+	before := serialFor(t, s, "example.test")
+
+	require.NoError(t, os.WriteFile(pair.KeyFile, []byte("not a key"), 0o600))
+	assert.Error(t, s.Reload())
+	assert.Equal(t, before, serialFor(t, s, "example.test"))
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeKeyPair(t, dir, "site", "example.test")
+	s, err := New(pair)
+	require.NoError(t, err)
+	before := serialFor(t, s, "example.test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Watch(ctx, 10*time.Millisecond)
+
+	writeKeyPair(t, dir, "site", "example.test")
+	// Make the change visible even on file systems with coarse timestamps.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(pair.CertFile, later, later))
+	require.NoError(t, os.Chtimes(pair.KeyFile, later, later))
+
+	expected := leafSerial(t, pair)
+	assert.Eventually(t, func() bool {
+		return serialFor(t, s, "example.test").Cmp(expected) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotEqual(t, before, expected)
+}
+
+func TestTLSConfig_Handshake(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeKeyPair(t, dir, "site", "example.test")
+	s, err := New(pair)
+	require.NoError(t, err)
+
+	config, err := s.TLSConfig()
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	pemData, err := os.ReadFile(pair.CertFile)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(pemData))
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "example.test"})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, leafSerial(t, pair), conn.ConnectionState().PeerCertificates[0].SerialNumber)
+}
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T, name string) authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return authority{cert: cert, key: key}
+}
+
+func (a authority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return pool
+}
+
+// clientCertificate issues a client certificate for subject and uris.
+func (a authority) clientCertificate(t *testing.T, subject pkix.Name, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, raw := range uris {
+		uri, err := url.Parse(raw)
+		require.NoError(t, err)
+		template.URIs = append(template.URIs, uri)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshake connects a client presenting clientCert, if any, to a server using
+// config and returns the server's view of the connection. The certificate is
+// sent even when the server does not list its issuer as acceptable.
+func handshake(t *testing.T, config *tls.Config, clientCert *tls.Certificate) (tls.ConnectionState, error) {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	type result struct {
+		state tls.ConnectionState
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		err = tlsConn.Handshake()
+		results <- result{state: tlsConn.ConnectionState(), err: err}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if clientCert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return clientCert, nil
+		},
+	})
+	if err == nil {
+		defer conn.Close()
+	}
+	r := <-results
+	return r.state, r.err
+}
+
+func TestParseClientAuth(t *testing.T) {
+	for _, policy := range []ClientAuth{ClientAuthNone, ClientAuthRequest, ClientAuthRequireAndVerify} {
+		parsed, err := ParseClientAuth(policy.String())
+		require.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+	_, err := ParseClientAuth("optional")
+	assert.Error(t, err)
+}
+
+func TestLoadCAPool(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeKeyPair(t, dir, "ca", "ca.test")
+	pool, err := LoadCAPool(pair.CertFile)
+	require.NoError(t, err)
+	assert.NotNil(t, pool)
+
+	_, err = LoadCAPool(pair.KeyFile)
+	assert.Error(t, err)
+	_, err = LoadCAPool()
+	assert.Error(t, err)
+}
+
+func TestTLSConfig_ClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(writeKeyPair(t, dir, "site", "example.test"))
+	require.NoError(t, err)
+
+	ca := newAuthority(t, "Test CA")
+	other := newAuthority(t, "Other CA")
+	trusted := ca.clientCertificate(t, pkix.Name{CommonName: "billing"}, "spiffe://example.test/billing")
+	untrusted := other.clientCertificate(t, pkix.Name{CommonName: "billing"})
+
+	_, err = s.TLSConfig(WithClientAuth(ClientAuthRequireAndVerify, nil))
+	assert.Error(t, err)
+
+	tests := []struct {
+		name       string
+		policy     ClientAuth
+		client     *tls.Certificate
+		fails      bool
+		verifiedCN string
+	}{
+		{"none ignores certificates", ClientAuthNone, &trusted, false, ""},
+		{"request without certificate", ClientAuthRequest, nil, false, ""},
+		{"request verifies a given certificate", ClientAuthRequest, &trusted, false, "billing"},
+		{"request rejects an untrusted certificate", ClientAuthRequest, &untrusted, true, ""},
+		{"require without certificate", ClientAuthRequireAndVerify, nil, true, ""},
+		{"require rejects an untrusted certificate", ClientAuthRequireAndVerify, &untrusted, true, ""},
+		{"require with trusted certificate", ClientAuthRequireAndVerify, &trusted, false, "billing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := s.TLSConfig(WithClientAuth(tt.policy, ca.pool()))
+			require.NoError(t, err)
+			state, err := handshake(t, config, tt.client)
+			if tt.fails {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.verifiedCN == "" {
+				assert.Empty(t, state.VerifiedChains)
+				return
+			}
+			require.NotEmpty(t, state.VerifiedChains)
+			chain := state.VerifiedChains[0]
+			assert.Equal(t, tt.verifiedCN, chain[0].Subject.CommonName)
+			assert.Equal(t, "Test CA", chain[len(chain)-1].Subject.CommonName)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ClientAuth is the policy for client certificates.
+type ClientAuth int
+
+const (
+	// ClientAuthNone does not ask for a client certificate.
+	ClientAuthNone ClientAuth = iota
+	// ClientAuthRequest asks for a certificate but accepts clients without
+	// one. A certificate that is sent must verify against the CA pool.
+	ClientAuthRequest
+	// ClientAuthRequireAndVerify refuses the handshake unless the client
+	// sends a certificate that verifies against the CA pool.
+	ClientAuthRequireAndVerify
+)
+
+var clientAuthNames = map[ClientAuth]string{
+	ClientAuthNone:             "none",
+	ClientAuthRequest:          "request",
+	ClientAuthRequireAndVerify: "require-and-verify",
+}
+
+func (c ClientAuth) String() string {
+	if name, ok := clientAuthNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ClientAuth(%d)", int(c))
+}
+
+// ParseClientAuth accepts the names printed by String.
+func ParseClientAuth(name string) (ClientAuth, error) {
+	for policy, policyName := range clientAuthNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+	return ClientAuthNone, fmt.Errorf("unknown client auth policy %q", name)
+}
+
+// ConfigOption adjusts the configuration returned by Store.TLSConfig.
+type ConfigOption func(*tls.Config) error
+
+// WithClientAuth applies policy, verifying client certificates against roots.
+// Verifying policies refuse a nil pool rather than trusting the system roots.
+func WithClientAuth(policy ClientAuth, roots *x509.CertPool) ConfigOption {
+	return func(config *tls.Config) error {
+		switch policy {
+		case ClientAuthNone:
+			config.ClientAuth = tls.NoClientCert
+			return nil
+		case ClientAuthRequest:
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		case ClientAuthRequireAndVerify:
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return fmt.Errorf("unknown client auth policy %v", policy)
+		}
+		if roots == nil {
+			return fmt.Errorf("client auth policy %v needs a CA pool", policy)
+		}
+		config.ClientCAs = roots
+		return nil
+	}
+}
+
+// LoadCAPool reads PEM certificates from files into a pool.
+func LoadCAPool(files ...string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CA files given")
+	}
+	pool := x509.NewCertPool()
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", name)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,10 @@
+package certs
+
+import "time"
+
+const (
+	wildcardPrefix = "*."
+	labelSeparator = "."
+
+	defaultWatchInterval = 10 * time.Second
+)
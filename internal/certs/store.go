@@ -0,0 +1,126 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// New loads every key pair. The first one is served to clients that send no
+// SNI name or one that no certificate covers.
+func New(pairs ...KeyPair) (*Store, error) {
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no certificate key pairs given")
+	}
+	s := &Store{pairs: pairs}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads every key pair from disk again. If any of them fails to load
+// the previous certificates stay in use.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make(map[string]fileStamp, 2*len(s.pairs))
+	for _, pair := range s.pairs {
+		for _, name := range []string{pair.CertFile, pair.KeyFile} {
+			stamp, err := stat(name)
+			if err != nil {
+				return err
+			}
+			files[name] = stamp
+		}
+	}
+	loaded, err := load(s.pairs)
+	if err != nil {
+		return err
+	}
+	s.current.Store(loaded)
+	s.files = files
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.current.Load().lookup(hello.ServerName), nil
+}
+
+// TLSConfig returns a server configuration that takes its certificates from
+// the store, adjusted by opts.
+func (s *Store) TLSConfig(opts ...ConfigOption) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.GetCertificate,
+	}
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func load(pairs []KeyPair) (*certificates, error) {
+	c := &certificates{
+		exact:     make(map[string]*tls.Certificate),
+		wildcards: make(map[string]*tls.Certificate),
+	}
+	for _, pair := range pairs {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", pair.CertFile, err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", pair.CertFile, err)
+		}
+		cert.Leaf = leaf
+		if c.fallback == nil {
+			c.fallback = &cert
+		}
+		for _, name := range leaf.DNSNames {
+			name = strings.ToLower(name)
+			if suffix, ok := strings.CutPrefix(name, wildcardPrefix); ok {
+				addFirst(c.wildcards, suffix, &cert)
+			} else {
+				addFirst(c.exact, name, &cert)
+			}
+		}
+	}
+	return c, nil
+}
+
+// addFirst keeps the certificate of the earliest key pair for a name.
+func addFirst(certs map[string]*tls.Certificate, name string, cert *tls.Certificate) {
+	if _, ok := certs[name]; !ok {
+		certs[name] = cert
+	}
+}
+
+// lookup prefers an exact name, then a wildcard covering the first label,
+// then the fallback.
+func (c *certificates) lookup(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, labelSeparator))
+	if cert, ok := c.exact[name]; ok {
+		return cert
+	}
+	if _, parent, ok := strings.Cut(name, labelSeparator); ok {
+		if cert, ok := c.wildcards[parent]; ok {
+			return cert
+		}
+	}
+	return c.fallback
+}
+
+func stat(name string) (fileStamp, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
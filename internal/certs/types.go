@@ -0,0 +1,37 @@
+package certs
+
+import (
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyPair names the PEM files of a certificate chain and its private key.
+type KeyPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Store serves certificates loaded from key pairs, chosen by SNI. Reloads
+// swap the whole set at once, so handshakes in progress keep the set they
+// started with.
+type Store struct {
+	pairs   []KeyPair
+	current atomic.Pointer[certificates]
+
+	mu    sync.Mutex
+	files map[string]fileStamp
+}
+
+type certificates struct {
+	exact     map[string]*tls.Certificate
+	wildcards map[string]*tls.Certificate
+	fallback  *tls.Certificate
+}
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
@@ -0,0 +1,71 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ReloadOnSignal reloads the certificates whenever one of signals arrives,
+// SIGHUP if none are given, until ctx is done.
+func (s *Store) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigChan:
+				s.reportReload()
+			}
+		}
+	}()
+}
+
+// Watch checks the certificate and key files every interval and reloads
+// when any of them changed, until ctx is done. A zero interval means 10s.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if s.changed() {
+					s.reportReload()
+				}
+			}
+		}
+	}()
+}
+
+func (s *Store) changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, stamp := range s.files {
+		current, err := stat(name)
+		if err != nil || !current.modTime.Equal(stamp.modTime) || current.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) reportReload() {
+	if err := s.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reloading certificates: %v\n", err)
+	}
+}
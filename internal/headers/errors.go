@@ -0,0 +1,8 @@
+package headers
+
+import "errors"
+
+var (
+	ErrInvalidFieldName  = errors.New("invalid header field name")
+	ErrInvalidFieldValue = errors.New("invalid header field value")
+)
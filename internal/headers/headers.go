@@ -1,20 +1,15 @@
 package headers
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 )
 
 const (
-	// Header key-value parsing
-	headerKeyValuePartsCount = 2
-	headerKeyIndex           = 0
-	headerValueIndex         = 1
-
 	// String literals
-	emptyString            = ""
-	colonDelimiter         = ":"
 	headerValueSeparator   = ", "
+	listDelimiter          = ","
 	carriageReturnLineFeed = "\r\n"
 	headersEndMarker       = "\r\n\r\n"
 
@@ -24,116 +19,186 @@ const (
 	colonRune  = ':'
 )
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
-	headerString := string(data)
-
-	if strings.HasPrefix(headerString, headersEndMarker) {
+// Parse adds the fields of a complete field section to h and reports the
+// bytes consumed, including the empty line that ends it. Until that line has
+// arrived it consumes nothing.
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
+	if bytes.HasPrefix(data, []byte(headersEndMarker)) {
 		return len(headersEndMarker), true, nil
 	}
 
-	if strings.HasPrefix(headerString, carriageReturnLineFeed) {
-		return len(carriageReturnLineFeed), true, nil
+	var parsed []Field
+	for {
+		lineLength := bytes.Index(data[n:], []byte(carriageReturnLineFeed))
+		if lineLength == -1 {
+			return 0, false, nil
+		}
+		line := data[n : n+lineLength]
+		n += lineLength + len(carriageReturnLineFeed)
+		if len(line) == 0 {
+			h.fields = append(h.fields, parsed...)
+			return n, true, nil
+		}
+		field, err := ParseFieldLine(line)
+		if err != nil {
+			return 0, false, err
+		}
+		parsed = append(parsed, field)
 	}
+}
 
-	endIdx, found := findHeaderBlockEnd(headerString)
-	if !found {
-		return 0, false, nil
+// ParseFieldLine parses a single field line without its CRLF. Only the name
+// and value are copied out of line.
+func ParseFieldLine(line []byte) (Field, error) {
+	colon := bytes.IndexByte(line, colonRune)
+	if colon == colonNotFound {
+		return Field{}, fmt.Errorf("malinformed header: %s", line)
+	}
+	if colon != firstCharacterIndex && (line[colon-1] == spaceCharacter || line[colon-1] == tabCharacter) {
+		return Field{}, fmt.Errorf("has a space before colon, header: %s", line)
 	}
 
-	if err := h.processHeaderBlock(headerString[:endIdx]); err != nil {
-		return 0, false, err
+	key := bytes.TrimSpace(line[:colon])
+	if err := validateHeaderKey(key); err != nil {
+		return Field{}, err
 	}
 
-	return endIdx + len(headersEndMarker), true, nil
+	value := bytes.TrimSpace(line[colon+1:])
+	if err := validateFieldValue(value); err != nil {
+		return Field{}, err
+	}
+	return Field{Name: string(key), Value: string(value)}, nil
 }
 
-func findHeaderBlockEnd(headerString string) (int, bool) {
-	endIdx := strings.Index(headerString, headersEndMarker)
-	return endIdx, endIdx != -1
+// Validate checks the name and value of a field before it is written.
+func (f Field) Validate() error {
+	if err := validateHeaderKey(f.Name); err != nil {
+		return err
+	}
+	return validateFieldValue(f.Value)
 }
 
-func (h Headers) processHeaderBlock(headerBlock string) error {
-	headerLines := strings.Split(headerBlock, carriageReturnLineFeed)
-	for _, headerLine := range headerLines {
-		if err := h.parseHeaderLine(headerLine); err != nil {
+// Validate reports the first field that cannot be written as is.
+func (h *Headers) Validate() error {
+	if h == nil {
+		return nil
+	}
+	for _, field := range h.fields {
+		if err := field.Validate(); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (h Headers) parseHeaderLine(headerLine string) error {
-	if headerLine == emptyString {
+func (h *Headers) Get(key string) string {
+	return strings.Join(h.Values(key), headerValueSeparator)
+}
+
+func (h *Headers) Values(key string) []string {
+	if h == nil {
 		return nil
 	}
-
-	if err := validateHeaderLineFormat(headerLine); err != nil {
-		return err
+	var values []string
+	for _, field := range h.fields {
+		if strings.EqualFold(field.Name, key) {
+			values = append(values, field.Value)
+		}
 	}
+	return values
+}
 
-	key, value, err := parseHeaderKeyValue(headerLine)
-	if err != nil {
-		return err
+func (h *Headers) Has(key string) bool {
+	if h == nil {
+		return false
 	}
+	for _, field := range h.fields {
+		if strings.EqualFold(field.Name, key) {
+			return true
+		}
+	}
+	return false
+}
 
-	h.setHeaderValue(key, value)
-	return nil
+func (h *Headers) Add(key, value string) {
+	h.fields = append(h.fields, Field{Name: key, Value: value})
 }
 
-func validateHeaderLineFormat(headerLine string) error {
-	if hasSpaceBeforeColon(headerLine) {
-		return fmt.Errorf("has a space before colon, header: %v", headerLine)
+// AppendToLast continues the value of the last field with a space and value,
+// which is how an obs-fold continuation line is folded back in.
+func (h *Headers) AppendToLast(value string) error {
+	if len(h.fields) == 0 {
+		return fmt.Errorf("continuation line without a field")
+	}
+	if err := validateFieldValue(value); err != nil {
+		return err
+	}
+	last := &h.fields[len(h.fields)-1]
+	if last.Value == "" {
+		last.Value = value
+	} else if value != "" {
+		last.Value += " " + value
 	}
 	return nil
 }
 
-func parseHeaderKeyValue(headerLine string) (string, string, error) {
-	kv := strings.SplitN(headerLine, colonDelimiter, headerKeyValuePartsCount)
-	if len(kv) != headerKeyValuePartsCount {
-		return emptyString, emptyString, fmt.Errorf("malinformed header: %v lenkv: %v", headerLine, len(kv))
+// Set replaces every field named key with a single field at the position
+// of the first one.
+func (h *Headers) Set(key, value string) {
+	for i, field := range h.fields {
+		if strings.EqualFold(field.Name, key) {
+			h.fields[i] = Field{Name: key, Value: value}
+			h.fields = append(h.fields[:i+1], removeFields(h.fields[i+1:], key)...)
+			return
+		}
 	}
+	h.Add(key, value)
+}
 
-	rawKey := strings.TrimSpace(kv[headerKeyIndex])
-	if rawKey == emptyString {
-		return emptyString, emptyString, fmt.Errorf("empty header key")
-	}
+func (h *Headers) Del(key string) {
+	h.fields = removeFields(h.fields, key)
+}
 
-	if err := validateHeaderKey(rawKey); err != nil {
-		return emptyString, emptyString, err
+func (h *Headers) Clone() *Headers {
+	if h == nil {
+		return NewHeaders()
 	}
-
-	key := strings.ToLower(rawKey)
-	value := strings.TrimSpace(kv[headerValueIndex])
-	return key, value, nil
+	return &Headers{fields: h.Fields()}
 }
 
-func (h Headers) setHeaderValue(key, value string) {
-	if h[key] == emptyString {
-		h[key] = value
-	} else {
-		h[key] += headerValueSeparator
-		h[key] += value
+func (h *Headers) Len() int {
+	if h == nil {
+		return 0
 	}
+	return len(h.fields)
 }
 
-func (h Headers) Get(key string) (string, error) {
-	if err := validateHeaderKey(key); err != nil {
-		return "", err
+func (h *Headers) Fields() []Field {
+	if h == nil {
+		return nil
 	}
-	return h[strings.ToLower(key)], nil
+	fields := make([]Field, len(h.fields))
+	copy(fields, h.fields)
+	return fields
 }
 
-func (h Headers) Set(key, value string) error {
-	if err := validateHeaderKey(key); err != nil {
-		return err
+func (h *Headers) HasToken(key, token string) bool {
+	for _, value := range h.Values(key) {
+		for _, element := range strings.Split(value, listDelimiter) {
+			if strings.EqualFold(strings.TrimSpace(element), token) {
+				return true
+			}
+		}
 	}
-	lowerKey := strings.ToLower(key)
-	for existingKey := range h {
-		if strings.ToLower(existingKey) == lowerKey {
-			delete(h, existingKey)
-			break
+	return false
+}
+
+func removeFields(fields []Field, key string) []Field {
+	kept := fields[:0]
+	for _, field := range fields {
+		if !strings.EqualFold(field.Name, key) {
+			kept = append(kept, field)
 		}
 	}
-	h[lowerKey] = value
-	return nil
+	return kept
 }
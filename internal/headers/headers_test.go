@@ -15,7 +15,7 @@ func TestHeadersParse(t *testing.T) {
 		n, done, err := headers.Parse(data)
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "localhost:42069", headers["host"]) // key lowercased
+		assert.Equal(t, "localhost:42069", headers.Get("host")) // lookup is case-insensitive
 		assert.Equal(t, len("HOST: localhost:42069\r\n\r\n"), n)
 		assert.True(t, done)
 	})
@@ -26,14 +26,14 @@ func TestHeadersParse(t *testing.T) {
 		n, done, err := headers.Parse(data)
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "localhost:42069", headers["host"]) // value should be trimmed
+		assert.Equal(t, "localhost:42069", headers.Get("host")) // value should be trimmed
 		assert.Equal(t, len("    Host:    localhost:42069    \r\n\r\n"), n)
 		assert.True(t, done)
 	})
 
 	t.Run("Valid 2 headers with existing headers", func(t *testing.T) {
 		headers := NewHeaders()
-		headers["x-preset"] = "foo"
+		headers.Set("x-preset", "foo")
 
 		data := []byte("Host: localhost:42069\r\nUser-Agent: TestClient\r\n\r\n")
 		n, done, err := headers.Parse(data)
@@ -41,9 +41,9 @@ func TestHeadersParse(t *testing.T) {
 		require.NotNil(t, headers)
 
 		// Verify both headers parsed + preset remains
-		assert.Equal(t, "localhost:42069", headers["host"])
-		assert.Equal(t, "TestClient", headers["user-agent"])
-		assert.Equal(t, "foo", headers["x-preset"])
+		assert.Equal(t, "localhost:42069", headers.Get("host"))
+		assert.Equal(t, "TestClient", headers.Get("user-agent"))
+		assert.Equal(t, "foo", headers.Get("x-preset"))
 		assert.Equal(t, len("Host: localhost:42069\r\nUser-Agent: TestClient\r\n\r\n"), n)
 		assert.True(t, done)
 	})
@@ -56,7 +56,7 @@ func TestHeadersParse(t *testing.T) {
 		require.NotNil(t, headers)
 		assert.Equal(t, 4, n) // len("\r\n\r\n")
 		assert.True(t, done)
-		assert.Empty(t, headers)
+		assert.Equal(t, 0, headers.Len())
 	})
 
 	t.Run("Invalid spacing header", func(t *testing.T) {
@@ -83,8 +83,8 @@ func TestHeadersParse(t *testing.T) {
 		n, done, err := headers.Parse(data)
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "application/json", headers["content-type"])
-		assert.Equal(t, "Value123", headers["x-custom-header"])
+		assert.Equal(t, "application/json", headers.Get("content-type"))
+		assert.Equal(t, "Value123", headers.Get("x-custom-header"))
 		assert.Equal(t, len("Content-Type: application/json\r\nX-Custom-Header: Value123\r\n\r\n"), n)
 		assert.True(t, done)
 	})
@@ -95,8 +95,8 @@ func TestHeadersParse(t *testing.T) {
 		n, done, err := headers.Parse(data)
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "value", headers["x-test_header"])
-		assert.Equal(t, "value2", headers["x-test-header"])
+		assert.Equal(t, "value", headers.Get("x-test_header"))
+		assert.Equal(t, "value2", headers.Get("x-test-header"))
 		assert.Equal(t, len("X-Test_Header: value\r\nX-Test-Header: value2\r\n\r\n"), n)
 		assert.True(t, done)
 	})
@@ -126,7 +126,7 @@ func TestHeadersParse(t *testing.T) {
 		n, done, err := headers.Parse(data)
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, longValue, headers["x-long-header"])
+		assert.Equal(t, longValue, headers.Get("x-long-header"))
 		assert.Equal(t, len("X-Long-Header: "+longValue+"\r\n\r\n"), n)
 		assert.True(t, done)
 	})
@@ -150,7 +150,7 @@ func TestHeadersParse(t *testing.T) {
 
 		// Verify values are combined with commas
 		expected := "lane-loves-go, prime-loves-zig, tj-loves-ocaml"
-		assert.Equal(t, expected, headers["set-person"])
+		assert.Equal(t, expected, headers.Get("set-person"))
 
 		// Verify consumed bytes and done flag
 		assert.Equal(t, len("Set-Person: lane-loves-go\r\nSet-Person: prime-loves-zig\r\nSet-Person: tj-loves-ocaml\r\n\r\n"), n)
@@ -159,7 +159,7 @@ func TestHeadersParse(t *testing.T) {
 
 	t.Run("Append to existing header key", func(t *testing.T) {
 		headers := NewHeaders()
-		headers["set-person"] = "already-here"
+		headers.Set("set-person", "already-here")
 
 		data := []byte("Set-Person: lane-loves-go\r\nSet-Person: prime-loves-zig\r\n\r\n")
 		n, done, err := headers.Parse(data)
@@ -168,10 +168,136 @@ func TestHeadersParse(t *testing.T) {
 
 		// Expect existing value + appended new values
 		expected := "already-here, lane-loves-go, prime-loves-zig"
-		assert.Equal(t, expected, headers["set-person"])
+		assert.Equal(t, expected, headers.Get("set-person"))
 
 		assert.Equal(t, len("Set-Person: lane-loves-go\r\nSet-Person: prime-loves-zig\r\n\r\n"), n)
 		assert.True(t, done)
 	})
 
 }
+
+func TestHeadersHasToken(t *testing.T) {
+	headers := NewHeaders()
+	headers.Add("Connection", "keep-alive, Upgrade")
+
+	assert.True(t, headers.HasToken("connection", "upgrade"))
+	assert.True(t, headers.HasToken("CONNECTION", "Keep-Alive"))
+	assert.False(t, headers.HasToken("Connection", "close"))
+	assert.False(t, headers.HasToken("Transfer-Encoding", "chunked"))
+}
+
+func TestHeadersPreserveOrderAndCasing(t *testing.T) {
+	headers := NewHeaders()
+	data := []byte("Host: localhost\r\nSet-Cookie: a=1\r\nX-Custom: one\r\nset-cookie: b=2, c=3\r\n\r\n")
+	_, done, err := headers.Parse(data)
+	require.NoError(t, err)
+	require.True(t, done)
+
+	assert.Equal(t, []Field{
+		{Name: "Host", Value: "localhost"},
+		{Name: "Set-Cookie", Value: "a=1"},
+		{Name: "X-Custom", Value: "one"},
+		{Name: "set-cookie", Value: "b=2, c=3"},
+	}, headers.Fields())
+	assert.Equal(t, []string{"a=1", "b=2, c=3"}, headers.Values("SET-COOKIE"))
+	assert.Equal(t, "a=1, b=2, c=3", headers.Get("Set-Cookie"))
+}
+
+func TestHeadersMutation(t *testing.T) {
+	headers := NewHeaders()
+	headers.Add("Vary", "Accept")
+	headers.Add("Content-Type", "text/plain")
+	headers.Add("vary", "Origin")
+	headers.Add("X-Trace", "1")
+
+	t.Run("Set replaces all values in place", func(t *testing.T) {
+		h := headers.Clone()
+		h.Set("VARY", "*")
+		assert.Equal(t, []Field{
+			{Name: "VARY", Value: "*"},
+			{Name: "Content-Type", Value: "text/plain"},
+			{Name: "X-Trace", Value: "1"},
+		}, h.Fields())
+	})
+
+	t.Run("Set appends a missing key", func(t *testing.T) {
+		h := headers.Clone()
+		h.Set("Content-Length", "0")
+		assert.Equal(t, "0", h.Get("content-length"))
+		assert.Equal(t, 5, h.Len())
+	})
+
+	t.Run("Del removes every value", func(t *testing.T) {
+		h := headers.Clone()
+		h.Del("vary")
+		assert.False(t, h.Has("Vary"))
+		assert.Nil(t, h.Values("Vary"))
+		assert.Equal(t, 2, h.Len())
+	})
+
+	t.Run("Clone is independent", func(t *testing.T) {
+		h := headers.Clone()
+		h.Add("X-Extra", "yes")
+		h.Set("X-Trace", "2")
+		assert.Equal(t, 4, headers.Len())
+		assert.Equal(t, "1", headers.Get("X-Trace"))
+	})
+
+	t.Run("Nil headers are empty", func(t *testing.T) {
+		var h *Headers
+		assert.Equal(t, "", h.Get("Host"))
+		assert.Equal(t, 0, h.Len())
+		assert.False(t, h.Has("Host"))
+		assert.Equal(t, 0, h.Clone().Len())
+	})
+}
+
+func TestHeadersParseRejectsInvalidValues(t *testing.T) {
+	for _, value := range []string{"a\x00b", "a\rb", "a\nb", "a\x07b", "a\x7fb"} {
+		headers := NewHeaders()
+		n, done, err := headers.Parse([]byte("X-Test: " + value + "\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidFieldValue, "value %q", value)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+	}
+
+	headers := NewHeaders()
+	_, _, err := headers.Parse([]byte("X-Test: tab\tand obs-text \xe9\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "tab\tand obs-text \xe9", headers.Get("X-Test"))
+}
+
+func TestHeadersParseRejectsDelimitersInNames(t *testing.T) {
+	for _, name := range []string{"Bad(Name", "a/b", "x@y"} {
+		headers := NewHeaders()
+		_, _, err := headers.Parse([]byte(name + ": v\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidFieldName, "name %q", name)
+	}
+}
+
+func TestIsToken(t *testing.T) {
+	assert.True(t, IsToken("GET"))
+	assert.True(t, IsToken("M-SEARCH"))
+	for _, s := range []string{"", "G\rET", "GE T", "a/b", "x@y", "caf\xe9"} {
+		assert.False(t, IsToken(s), "token %q", s)
+	}
+}
+
+func TestFieldValidate(t *testing.T) {
+	assert.NoError(t, Field{Name: "X-Valid", Value: "some value"}.Validate())
+	assert.NoError(t, Field{Name: "X-Empty", Value: ""}.Validate())
+	assert.ErrorIs(t, Field{Name: "", Value: "v"}.Validate(), ErrInvalidFieldName)
+	assert.ErrorIs(t, Field{Name: "Bad Name", Value: "v"}.Validate(), ErrInvalidFieldName)
+	assert.ErrorIs(t, Field{Name: "X-Injected\r\nEvil", Value: "v"}.Validate(), ErrInvalidFieldName)
+	for _, name := range []string{"Bad(Name", "a/b", "x@y", `q"uote`, "s;c", "b[r]", "e=q", "c{u}"} {
+		assert.ErrorIs(t, Field{Name: name, Value: "v"}.Validate(), ErrInvalidFieldName, "name %q", name)
+	}
+	assert.NoError(t, Field{Name: "!#$%&'*+-.^_`|~09azAZ", Value: "v"}.Validate())
+	assert.ErrorIs(t, Field{Name: "X-Echo", Value: "v\r\nSet-Cookie: evil=1"}.Validate(), ErrInvalidFieldValue)
+
+	headers := NewHeaders()
+	headers.Add("X-Ok", "fine")
+	require.NoError(t, headers.Validate())
+	headers.Add("X-Bad", "line\nbreak")
+	assert.ErrorIs(t, headers.Validate(), ErrInvalidFieldValue)
+}
@@ -1,7 +1,14 @@
 package headers
 
-type Headers map[string]string
+type Field struct {
+	Name  string
+	Value string
+}
+
+type Headers struct {
+	fields []Field
+}
 
-func NewHeaders() Headers {
-	return make(Headers)
+func NewHeaders() *Headers {
+	return &Headers{}
 }
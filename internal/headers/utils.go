@@ -9,23 +9,57 @@ const (
 	colonNotFound       = -1
 	firstCharacterIndex = 0
 	spaceCharacter      = ' '
+	tabCharacter        = '\t'
+	deleteCharacter     = 0x7f
+
+	tokenDelimiters = `"(),/:;<=>?@[\]{}`
 )
 
-func hasSpaceBeforeColon(s string) bool {
-	idx := strings.IndexRune(s, colonRune)
-	if idx == colonNotFound || idx == firstCharacterIndex {
+// validateHeaderKey checks that the name is a non-empty token (RFC 9110
+// section 5.1).
+func validateHeaderKey[T string | []byte](rawKey T) error {
+	if len(rawKey) == 0 {
+		return fmt.Errorf("%w: empty name", ErrInvalidFieldName)
+	}
+	for i := 0; i < len(rawKey); i++ {
+		if !isTokenChar(rawKey[i]) {
+			return fmt.Errorf("%w: invalid character in %q", ErrInvalidFieldName, rawKey)
+		}
+	}
+	return nil
+}
+
+// IsToken reports whether s is a non-empty RFC 9110 token, the syntax of
+// field names and request methods.
+func IsToken(s string) bool {
+	if s == "" {
 		return false
 	}
-	if s[idx-1] == spaceCharacter {
-		return true
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar reports whether c is a tchar: a visible ASCII character other
+// than the delimiters of RFC 9110 section 5.6.2.
+func isTokenChar(c byte) bool {
+	if c <= asciiSpace || c > asciiTilde {
+		return false
 	}
-	return false
+	return strings.IndexByte(tokenDelimiters, c) == -1
 }
 
-func validateHeaderKey(rawKey string) error {
-	for _, r := range rawKey {
-		if r <= asciiSpace || r > asciiTilde || r == colonRune {
-			return fmt.Errorf("invalid character in header key: %v", rawKey)
+// validateFieldValue checks the characters of RFC 9110 section 5.5
+// field-value: visible characters, obs-text, space and tab. CR, LF, NUL and
+// the other control characters are refused, so a value can never end the
+// field line early.
+func validateFieldValue[T string | []byte](value T) error {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c != tabCharacter && (c < spaceCharacter || c == deleteCharacter) {
+			return fmt.Errorf("%w: invalid character %q in %q", ErrInvalidFieldValue, c, value)
 		}
 	}
 	return nil
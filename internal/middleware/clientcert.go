@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+)
+
+type identityKey struct{}
+
+// ClientIdentity admits requests whose verified client certificate maps to an
+// identity and answers everything else with 403. identities is keyed by SAN
+// URI (such as "spiffe://example.test/billing") or by subject in
+// pkix.Name.String form (such as "CN=billing,O=Example"); URIs are checked
+// first. The identity is available through IdentityFromContext.
+func ClientIdentity(identities map[string]string) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			identity, ok := identify(req, identities)
+			if !ok {
+				writeText(w, response.StatusForbidden, forbiddenBody)
+				return
+			}
+			req.SetContext(context.WithValue(req.Context(), identityKey{}, identity))
+			next(w, req)
+		}
+	}
+}
+
+func IdentityFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+func identify(req *request.Request, identities map[string]string) (string, bool) {
+	chain := req.VerifiedChain()
+	if len(chain) == 0 {
+		return "", false
+	}
+	leaf := chain[0]
+	for _, uri := range leaf.URIs {
+		if identity, ok := identities[uri.String()]; ok {
+			return identity, true
+		}
+	}
+	identity, ok := identities[leaf.Subject.String()]
+	return identity, ok
+}
@@ -0,0 +1,12 @@
+package middleware
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDBytes     = 16
+	maxRequestIDLength = 128
+
+	internalErrorBody = "Internal Server Error"
+	timeoutBody       = "Service Unavailable"
+	forbiddenBody     = "Forbidden"
+)
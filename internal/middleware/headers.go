@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+)
+
+// Headers adds the given fields to every response unless the handler writes
+// the same field itself.
+func Headers(h *headers.Headers) server.Middleware {
+	fields := h.Fields()
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			for _, field := range fields {
+				w.Header().Add(field.Name, field.Value)
+			}
+			next(w, req)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"log"
+	"time"
+)
+
+// Logger writes one line per request with the method, target, status and
+// duration, plus the request ID when RequestID runs before it.
+func Logger(l *log.Logger) server.Middleware {
+	if l == nil {
+		l = log.Default()
+	}
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			start := time.Now()
+			next(w, req)
+			duration := time.Since(start)
+			if id := RequestIDFromContext(req.Context()); id != "" {
+				l.Printf("%s %s %d %s id=%s", req.RequestLine.Method, req.RequestLine.RequestTarget, w.Status(), duration, id)
+				return
+			}
+			l.Printf("%s %s %d %s", req.RequestLine.Method, req.RequestLine.RequestTarget, w.Status(), duration)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, h server.Handler, raw string) (string, *response.Writer) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	h(w, req)
+	return buf.String(), w
+}
+
+const getRequest = "GET /path HTTP/1.1\r\nHost: localhost\r\n\r\n"
+
+func text(body string) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		_, _ = w.WriteBody([]byte(body))
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	trace := func(name string) server.Middleware {
+		return func(next server.Handler) server.Handler {
+			return func(w *response.Writer, req *request.Request) {
+				order = append(order, name)
+				next(w, req)
+			}
+		}
+	}
+
+	h := server.Chain(text("ok"), trace("first"), trace("second"))
+	_, _ = serve(t, h, getRequest)
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("writes 500 before any output", func(t *testing.T) {
+		h := Recover()(func(w *response.Writer, req *request.Request) {
+			panic("boom")
+		})
+		resp, w := serve(t, h, getRequest)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 500 Internal Server Error\r\n"), resp)
+		assert.Contains(t, resp, "Connection: close\r\n")
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("closes after partial output", func(t *testing.T) {
+		h := Recover()(func(w *response.Writer, req *request.Request) {
+			_ = w.WriteStatusLine(response.StatusOK)
+			panic("boom")
+		})
+		resp, w := serve(t, h, getRequest)
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n", resp)
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("leaves a streamed body unterminated", func(t *testing.T) {
+		h := Recover()(func(w *response.Writer, req *request.Request) {
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 5000))
+			panic("boom")
+		})
+		req, err := request.RequestFromReader(strings.NewReader(getRequest))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		w := response.NewWriter(&buf)
+		h(w, req)
+		written := buf.Len()
+
+		assert.ErrorIs(t, w.Finish(), response.ErrAborted)
+		assert.Equal(t, written, buf.Len())
+		assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+		assert.False(t, strings.HasSuffix(buf.String(), "0\r\n\r\n"))
+		assert.False(t, w.KeepAlive())
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	h := server.Chain(text("ok"), RequestID(), Logger(log.New(&out, "", 0)))
+
+	_, _ = serve(t, h, "GET /logged HTTP/1.1\r\nHost: localhost\r\nX-Request-ID: abc-123\r\n\r\n")
+	assert.Regexp(t, `^GET /logged 200 \S+ id=abc-123\n$`, out.String())
+
+	tests := []struct {
+		name    string
+		handler server.Handler
+		status  string
+	}{
+		{"explicit status", func(w *response.Writer, req *request.Request) {
+			writeText(w, response.StatusNotFound, "missing")
+		}, "404"},
+		{"implicit write", func(w *response.Writer, req *request.Request) {
+			_, _ = w.Write([]byte("body"))
+		}, "200"},
+		{"nothing written", func(w *response.Writer, req *request.Request) {}, "200"},
+		{"SetStatus only", func(w *response.Writer, req *request.Request) {
+			w.SetStatus(response.StatusAccepted)
+		}, "202"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			_, _ = serve(t, Logger(log.New(&out, "", 0))(tt.handler), getRequest)
+			assert.Regexp(t, `^GET /path `+tt.status+` \S+\n$`, out.String())
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID()(func(w *response.Writer, req *request.Request) {
+		seen = RequestIDFromContext(req.Context())
+		text("ok")(w, req)
+	})
+
+	resp, _ := serve(t, h, getRequest)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{32}$`), seen)
+	assert.Contains(t, resp, "X-Request-ID: "+seen+"\r\n")
+
+	resp, _ = serve(t, h, "GET / HTTP/1.1\r\nHost: localhost\r\nX-Request-ID: client.id_1\r\n\r\n")
+	assert.Equal(t, "client.id_1", seen)
+	assert.Contains(t, resp, "X-Request-ID: client.id_1\r\n")
+
+	_, _ = serve(t, h, "GET / HTTP/1.1\r\nHost: localhost\r\nX-Request-ID: bad id\r\n\r\n")
+	assert.NotEqual(t, "bad id", seen)
+	assert.Len(t, seen, 2*requestIDBytes)
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("fast handler", func(t *testing.T) {
+		h := Timeout(time.Second)(text("fast"))
+		resp, w := serve(t, h, getRequest)
+		assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 4\r\nContent-Type: text/plain\r\n\r\nfast", resp)
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		h := Timeout(20 * time.Millisecond)(func(w *response.Writer, req *request.Request) {
+			<-req.Context().Done()
+			close(cancelled)
+			text("late")(w, req)
+		})
+		resp, w := serve(t, h, getRequest)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 503 Service Unavailable\r\n"), resp)
+		assert.NotContains(t, resp, "late")
+		assert.False(t, w.KeepAlive())
+		<-cancelled
+	})
+
+	t.Run("head-only response", func(t *testing.T) {
+		h := Timeout(time.Second)(func(w *response.Writer, req *request.Request) {
+			w.Header().Set("Location", "/x")
+			w.SetStatus(response.StatusNoContent)
+		})
+		resp, w := serve(t, h, getRequest)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 204 No Content\r\n"), resp)
+		assert.Contains(t, resp, "Location: /x\r\n")
+		assert.NotContains(t, resp, "Content-Length")
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("redirect with hints", func(t *testing.T) {
+		h := Timeout(time.Second)(func(w *response.Writer, req *request.Request) {
+			_ = w.WriteInformational(response.StatusEarlyHints, nil)
+			w.Header().Set("Location", "/elsewhere")
+			w.SetStatus(response.StatusFound)
+		})
+		resp, _ := serve(t, h, getRequest)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 103 Early Hints\r\n\r\nHTTP/1.1 302 Found\r\n"), resp)
+		assert.Contains(t, resp, "Location: /elsewhere\r\n")
+	})
+
+	t.Run("panic propagates", func(t *testing.T) {
+		h := Recover()(Timeout(time.Second)(func(w *response.Writer, req *request.Request) {
+			panic("boom")
+		}))
+		resp, _ := serve(t, h, getRequest)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 500 Internal Server Error\r\n"), resp)
+	})
+}
+
+// TestTimeoutExpectContinue runs Timeout in front of a handler that reads a
+// body sent with "Expect: 100-continue", once before and once after the
+// deadline. The interim response must never race with or follow the 503.
+func TestTimeoutExpectContinue(t *testing.T) {
+	tests := []struct {
+		name          string
+		waitDeadline  bool
+		delay         time.Duration
+		allowContinue bool
+	}{
+		{"read before the deadline", false, 0, true},
+		{"read at the deadline", true, 0, true},
+		{"read after the deadline", true, 20 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerDone := make(chan struct{})
+			srv, err := server.Serve(0, Timeout(50*time.Millisecond)(func(w *response.Writer, req *request.Request) {
+				defer close(handlerDone)
+				if tt.waitDeadline {
+					<-req.Context().Done()
+					time.Sleep(tt.delay)
+				}
+				_, _ = io.ReadAll(req.Body)
+			}))
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = srv.Close() })
+
+			conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+			_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 4\r\n\r\n"))
+			require.NoError(t, err)
+
+			// The body is never sent, so the server closes after the 503.
+			resp, err := io.ReadAll(conn)
+			require.NoError(t, err)
+			<-handlerDone
+
+			final := strings.Index(string(resp), "HTTP/1.1 503 Service Unavailable\r\n")
+			require.GreaterOrEqual(t, final, 0, string(resp))
+			assert.NotContains(t, string(resp[final:]), "100 Continue")
+			if !tt.allowContinue {
+				assert.Equal(t, 0, final, string(resp))
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	extra := headers.NewHeaders()
+	extra.Set("X-Frame-Options", "DENY")
+	extra.Set("Content-Type", "text/html")
+	h := Headers(extra)(text("ok"))
+
+	resp, _ := serve(t, h, getRequest)
+	assert.Contains(t, resp, "X-Frame-Options: DENY\r\n")
+	assert.Contains(t, resp, "Content-Type: text/plain\r\n")
+	assert.NotContains(t, resp, "text/html")
+}
+
+// withClientCert sets a TLS state whose verified chain holds a leaf with
+// commonName and uris before calling next.
+func withClientCert(next server.Handler, commonName string, uris ...string) server.Handler {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: commonName, Organization: []string{"Example"}}}
+	for _, raw := range uris {
+		uri, _ := url.Parse(raw)
+		leaf.URIs = append(leaf.URIs, uri)
+	}
+	return func(w *response.Writer, req *request.Request) {
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+		next(w, req)
+	}
+}
+
+func TestClientIdentity(t *testing.T) {
+	var seen string
+	h := ClientIdentity(map[string]string{
+		"spiffe://example.test/billing": "billing",
+		"CN=reports,O=Example":          "reports",
+	})(func(w *response.Writer, req *request.Request) {
+		seen = IdentityFromContext(req.Context())
+		text("ok")(w, req)
+	})
+
+	tests := []struct {
+		name       string
+		handler    server.Handler
+		identity   string
+		statusLine string
+	}{
+		{"SAN URI", withClientCert(h, "billing-7", "spiffe://example.test/billing"), "billing", "HTTP/1.1 200 OK\r\n"},
+		{"subject", withClientCert(h, "reports"), "reports", "HTTP/1.1 200 OK\r\n"},
+		{"URI wins over subject", withClientCert(h, "reports", "spiffe://example.test/billing"), "billing", "HTTP/1.1 200 OK\r\n"},
+		{"unknown certificate", withClientCert(h, "intruder", "spiffe://example.test/intruder"), "", "HTTP/1.1 403 Forbidden\r\n"},
+		{"no certificate", h, "", "HTTP/1.1 403 Forbidden\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			resp, _ := serve(t, tt.handler, getRequest)
+			assert.True(t, strings.HasPrefix(resp, tt.statusLine), resp)
+			assert.Equal(t, tt.identity, seen)
+		})
+	}
+}
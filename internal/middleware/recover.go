@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"log"
+	"runtime/debug"
+)
+
+// Recover answers a panicking handler with 500 when nothing has been sent
+// yet, and otherwise aborts the response so its partial body is not
+// completed. The connection is closed either way since the request body and
+// the response may have been left half-done.
+func Recover() server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				log.Printf("panic serving %s %s: %v\n%s", req.RequestLine.Method, req.RequestLine.RequestTarget, p, debug.Stack())
+				w.SetKeepAlive(false)
+				if w.Reset() {
+					writeText(w, response.StatusInternalServerError, internalErrorBody)
+					return
+				}
+				w.Abort()
+			}()
+			next(w, req)
+		}
+	}
+}
+
+func writeText(w *response.Writer, statusCode response.StatusCode, body string) {
+	_ = w.WriteStatusLine(statusCode)
+	_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	_, _ = w.WriteBody([]byte(body))
+}
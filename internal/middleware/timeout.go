@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"time"
+)
+
+// Timeout runs the handler against a buffered writer and answers with 503 if
+// it has not finished within d. The request context is cancelled at the
+// deadline; output written after it is dropped and the connection is closed,
+// since the handler may still be reading the request body.
+func Timeout(d time.Duration) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			req.SetContext(ctx)
+
+			buffered := response.NewBufferedWriter(w)
+			done := make(chan any, 1)
+			go func() {
+				defer func() { done <- recover() }()
+				next(buffered, req)
+			}()
+
+			select {
+			case p := <-done:
+				if p != nil {
+					panic(p)
+				}
+				// Finish even when nothing was written: the handler may
+				// have set only a status and fields, as for 204 or a
+				// redirect.
+				if err := buffered.Finish(); err != nil {
+					w.SetKeepAlive(false)
+					return
+				}
+				if err := buffered.Commit(); err != nil {
+					w.SetKeepAlive(false)
+				}
+			case <-ctx.Done():
+				// The handler may still read the body, which would write
+				// 100 Continue to w concurrently with or after the 503.
+				req.CancelContinue()
+				w.SetKeepAlive(false)
+				writeText(w, response.StatusServiceUnavailable, timeoutBody)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package request
+
+import "io"
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyClosed
+	}
+	if err := b.req.sendContinue(); err != nil {
+		return 0, err
+	}
+	return b.reader.readBody(b.req, p)
+}
+
+func (b *body) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (r *Request) DiscardBody(limit int) error {
+	if r.body == nil {
+		return nil
+	}
+	scratch := make([]byte, discardBufferSize)
+	discarded := 0
+	for limit == unlimitedDiscard || discarded <= limit {
+		n, err := r.body.reader.readBody(r, scratch)
+		discarded += n
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ErrBodyNotDrained
+}
@@ -1,24 +1,31 @@
 package request
 
 const (
-	supportedHttpVersion = "1.1"
+	supportedHttpMajorVersion = '1'
+	httpVersion10             = "1.0"
+	httpVersionLength         = len("1.1")
+	httpProtocolName          = "HTTP"
 )
 
 const (
-	requestLineMethodIndex   = 0
-	requestLineTargetIndex   = 1
-	requestLineVersionIndex  = 2
-	expectedRequestLineParts = 3
+	readBufferSize    = 4 * 1024
+	unlimitedDiscard  = -1
+	discardBufferSize = 4 * 1024
 )
 
 const (
-	httpVersionPartsCount = 2
-	httpVersionValueIndex = 1
+	defaultMaxRequestLineLength = 8 * 1024
+	defaultMaxHeaderBytes       = 64 * 1024
+	defaultMaxHeaderCount       = 100
+	maxLeadingEmptyLines        = 1
+	unlimitedBodyBytes          = 0
 )
 
 const (
-	requestLineDataIndex = 0
-	minDataPartsCount    = 1
+	requestLineMethodIndex   = 0
+	requestLineTargetIndex   = 1
+	requestLineVersionIndex  = 2
+	expectedRequestLineParts = 3
 )
 
 const (
@@ -26,4 +33,47 @@ const (
 	spaceDelimiter         = " "
 	carriageReturnLineFeed = "\r\n"
 	slashDelimiter         = "/"
+	carriageReturn         = '\r'
+	lineFeed               = '\n'
+)
+
+const (
+	hostHeader             = "Host"
+	connectionHeader       = "Connection"
+	closeConnectionToken   = "close"
+	keepAliveToken         = "keep-alive"
+	contentLengthHeader    = "Content-Length"
+	transferEncodingHeader = "Transfer-Encoding"
+	chunkedTransferCoding  = "chunked"
+	expectHeader           = "Expect"
+	continueExpectation    = "100-continue"
+)
+
+const (
+	chunkExtensionDelimiter = ';'
+	chunkExtensionAssign    = '='
+	quoteCharacter          = '"'
+	escapeCharacter         = '\\'
+	tokenEndCharacters      = " \t;=\""
+	chunkSizeBase           = 16
+	maxChunkSizeDigits      = 15
+	maxContentLengthDigits  = 18
+	listDelimiter           = ","
+	optionalWhitespace      = " \t"
+)
+
+const (
+	methodConnect        = "CONNECT"
+	methodOptions        = "OPTIONS"
+	asteriskTarget       = "*"
+	schemeDelimiter      = "://"
+	queryDelimiter       = "?"
+	fragmentDelimiter    = "#"
+	userinfoDelimiter    = "@"
+	portDelimiter        = ":"
+	queryPairDelimiter   = "&"
+	queryValueDelimiter  = "="
+	percentEncodingWidth = 3
+	rootPath             = "/"
+	maxPort              = 65535
 )
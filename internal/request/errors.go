@@ -0,0 +1,46 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+)
+
+type ParseError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrBadRequest           = &ParseError{StatusCode: 400, Message: "bad request string"}
+	ErrRequestTimeout       = &ParseError{StatusCode: 408, Message: "request timeout"}
+	ErrContentTooLarge      = &ParseError{StatusCode: 413, Message: "request body too large"}
+	ErrURITooLong           = &ParseError{StatusCode: 414, Message: "request target too long"}
+	ErrExpectationFailed    = &ParseError{StatusCode: 417, Message: "expectation not supported"}
+	ErrHeaderFieldsTooLarge = &ParseError{StatusCode: 431, Message: "request header fields too large"}
+	ErrNotImplemented       = &ParseError{StatusCode: 501, Message: "transfer coding not implemented"}
+	ErrVersionNotSupported  = &ParseError{StatusCode: 505, Message: "http version not supported"}
+)
+
+var (
+	ErrBodyClosed     = errors.New("read on closed request body")
+	ErrBodyNotDrained = errors.New("unread request body exceeds discard limit")
+)
+
+func StatusCode(err error) int {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StatusCode
+	}
+	return ErrBadRequest.StatusCode
+}
+
+func wrapError(sentinel *ParseError, detail any) error {
+	if err, ok := detail.(error); ok {
+		return fmt.Errorf("%w: %w", sentinel, err)
+	}
+	return fmt.Errorf("%w: %v", sentinel, detail)
+}
@@ -0,0 +1,65 @@
+package request
+
+type Limits struct {
+	MaxRequestLineLength int
+	MaxHeaderBytes       int
+	MaxHeaderCount       int
+	MaxBodyBytes         int64
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxRequestLineLength: defaultMaxRequestLineLength,
+		MaxHeaderBytes:       defaultMaxHeaderBytes,
+		MaxHeaderCount:       defaultMaxHeaderCount,
+		MaxBodyBytes:         unlimitedBodyBytes,
+	}
+}
+
+// withDefaults fills zero fields from DefaultLimits. A zero MaxBodyBytes
+// leaves the body unbounded.
+func (l Limits) withDefaults() Limits {
+	defaults := DefaultLimits()
+	if l.MaxRequestLineLength <= 0 {
+		l.MaxRequestLineLength = defaults.MaxRequestLineLength
+	}
+	if l.MaxHeaderBytes <= 0 {
+		l.MaxHeaderBytes = defaults.MaxHeaderBytes
+	}
+	if l.MaxHeaderCount <= 0 {
+		l.MaxHeaderCount = defaults.MaxHeaderCount
+	}
+	return l
+}
+
+func (r *Request) LimitBody(maxBytes int64) error {
+	if maxBytes == unlimitedBodyBytes {
+		return nil
+	}
+	if r.limits.MaxBodyBytes == unlimitedBodyBytes || maxBytes < r.limits.MaxBodyBytes {
+		r.limits.MaxBodyBytes = maxBytes
+	}
+	if err := r.checkBodySize(0); err != nil {
+		r.bodyErr = err
+		// The body will not be read, so the client is not asked for it.
+		r.continueMu.Lock()
+		r.expectContinue = false
+		r.continueMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *Request) checkBodySize(n int) error {
+	if r.limits.MaxBodyBytes == unlimitedBodyBytes {
+		return nil
+	}
+	total := r.bodyRead + int64(n)
+	if r.State == ParsingBodyState {
+		total += int64(r.bodyRemaining)
+	}
+	if total > r.limits.MaxBodyBytes {
+		return wrapError(ErrContentTooLarge, total)
+	}
+	return nil
+}
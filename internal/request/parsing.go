@@ -1,27 +1,72 @@
 package request
 
 import (
-	"fmt"
-	"io"
+	"bytes"
+	"httpfromtcp/internal/headers"
 	"strconv"
 	"strings"
 )
 
 func (r *Request) ParseRequestLine(data []byte) (int, error) {
-	requestLine, consumed, err := parseRequestLine(string(data))
+	line, n, err := r.nextLine(data)
 	if err != nil {
 		return 0, err
 	}
-	if consumed == 0 {
-		return 0, nil
+	lineLength := len(line)
+	if n == 0 {
+		lineLength = len(data)
 	}
-	if requestLine == nil {
+	if lineLength > r.limits.MaxRequestLineLength {
+		return 0, wrapError(ErrURITooLong, lineLength)
+	}
+	if n == 0 {
 		return 0, nil
 	}
+	if len(line) == 0 && (r.lenient || r.emptyLines < maxLeadingEmptyLines) {
+		// RFC 9112 section 2.2: ignore at least one empty line before the
+		// request-line; lenient mode ignores any number of them.
+		r.emptyLines++
+		return n, nil
+	}
+
+	// RFC 9112 section 2.2: a CR outside a line ending is rejected.
+	if bytes.IndexByte(line, carriageReturn) != -1 {
+		return 0, wrapError(ErrBadRequest, "bare CR in request-line")
+	}
+	requestLine, err := validateAndParseRequestLine(string(line))
+	if err != nil {
+		return 0, err
+	}
+	target, err := ParseTarget(requestLine.Method, requestLine.RequestTarget)
+	if err != nil {
+		return 0, err
+	}
 
 	r.RequestLine = *requestLine
+	r.Target = target
 	r.State = ParsingHeadersState
-	return consumed, nil
+	return n, nil
+}
+
+// nextLine returns the next line at the start of data without its line
+// ending, and the bytes it spans including the ending; n is 0 while the line
+// is incomplete. The search resumes where the previous call stopped instead
+// of rescanning. Lines must end in CRLF; lenient mode also accepts a bare LF.
+func (r *Request) nextLine(data []byte) (line []byte, n int, err error) {
+	idx := bytes.IndexByte(data[r.scanned:], lineFeed)
+	if idx == -1 {
+		r.scanned = len(data)
+		return nil, 0, nil
+	}
+	end := r.scanned + idx
+	r.scanned = 0
+	if end > 0 && data[end-1] == carriageReturn {
+		return data[:end-1], end + 1, nil
+	}
+	if !r.lenient {
+		return nil, 0, wrapError(ErrBadRequest, "bare LF line ending")
+	}
+	return data[:end], end + 1, nil
 }
 
 func (r *Request) parseSingle(data []byte) (int, error) {
@@ -34,6 +79,14 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		return r.parseHeadersState(data)
 	case ParsingBodyState:
 		return r.parseBodyState(data)
+	case ParsingChunkSizeState:
+		return r.parseChunkSizeState(data)
+	case ParsingChunkDataState:
+		return r.parseChunkDataState(data)
+	case ParsingChunkDataEndState:
+		return r.parseChunkDataEndState(data)
+	case ParsingTrailersState:
+		return r.parseTrailersState(data)
 	default:
 		return 0, ErrBadRequest
 	}
@@ -44,95 +97,268 @@ func (r *Request) parsePendingState(data []byte) (int, error) {
 }
 
 func (r *Request) parseHeadersState(data []byte) (int, error) {
-	n, done, err := r.Headers.Parse(data)
+	return r.parseFieldLine(data, r.Headers, ParsingBodyState)
+}
+
+// parseFieldLine consumes one line of the header or trailer section, adding
+// its field to h, or moves to next on the empty line that ends the section.
+// The section limits are checked as lines arrive.
+func (r *Request) parseFieldLine(data []byte, h *headers.Headers, next ParseState) (int, error) {
+	line, consumed, err := r.nextLine(data)
 	if err != nil {
 		return 0, err
 	}
-	if done {
-		r.State = ParsingBodyState
+	if consumed == 0 {
+		if r.fieldBytes+len(data) > r.limits.MaxHeaderBytes {
+			return 0, wrapError(ErrHeaderFieldsTooLarge, r.fieldBytes+len(data))
+		}
+		return 0, nil
 	}
-	return n, nil
+	if len(line) == 0 {
+		r.fieldBytes = 0
+		r.fieldCount = 0
+		r.State = next
+		return consumed, nil
+	}
+	if r.fieldBytes+len(line) > r.limits.MaxHeaderBytes {
+		return 0, wrapError(ErrHeaderFieldsTooLarge, r.fieldBytes+len(line))
+	}
+	if isWhitespace(line[0]) {
+		if !r.lenient {
+			return 0, wrapError(ErrBadRequest, "obsolete line folding")
+		}
+		if h.Len() > 0 {
+			return r.unfold(line, consumed, h)
+		}
+	}
+	r.fieldCount++
+	if r.fieldCount > r.limits.MaxHeaderCount {
+		return 0, wrapError(ErrHeaderFieldsTooLarge, "too many header fields")
+	}
+	field, err := headers.ParseFieldLine(line)
+	if err != nil {
+		return 0, wrapError(ErrBadRequest, err)
+	}
+	h.Add(field.Name, field.Value)
+	r.fieldBytes += consumed
+	return consumed, nil
 }
 
-func (r *Request) parseBodyState(data []byte) (int, error) {
-	contentLengthString, err := r.Headers.Get("Content-Length")
-	if err != nil {
-		return 0, err
+// unfold joins an obs-fold continuation line (RFC 9112 section 5.2) to the
+// previous field with a single space. Strict mode rejects such lines, and
+// lenient mode parses one that has no field before it as a field of its own.
+func (r *Request) unfold(line []byte, consumed int, h *headers.Headers) (int, error) {
+	if err := h.AppendToLast(string(bytes.Trim(line, optionalWhitespace))); err != nil {
+		return 0, wrapError(ErrBadRequest, err)
+	}
+	r.fieldBytes += consumed
+	return consumed, nil
+}
+
+// initHost enforces RFC 9112 section 3.2: an HTTP/1.1 request carries exactly
+// one valid Host field. The authority of an absolute-form or authority-form
+// target takes precedence over the field value.
+func (r *Request) initHost() error {
+	values := r.Headers.Values(hostHeader)
+	if len(values) > 1 {
+		return wrapError(ErrBadRequest, "multiple Host header fields")
+	}
+	if len(values) == 0 {
+		if !r.IsHTTP10() {
+			return wrapError(ErrBadRequest, "missing Host header field")
+		}
+	} else if values[0] != emptyString {
+		if err := validateAuthority(values[0], false); err != nil {
+			return wrapError(ErrBadRequest, "invalid Host header field")
+		}
+		r.Host = values[0]
 	}
-	if contentLengthString == "" {
+	if r.Target.Authority != emptyString {
+		r.Host = r.Target.Authority
+	}
+	return nil
+}
+
+// initExpect accepts the 100-continue expectation and rejects any other with
+// 417. The expectation only matters when a body follows.
+func (r *Request) initExpect() error {
+	for _, value := range r.Headers.Values(expectHeader) {
+		for _, expectation := range strings.Split(value, listDelimiter) {
+			expectation = strings.Trim(expectation, optionalWhitespace)
+			if expectation == emptyString {
+				continue
+			}
+			if !strings.EqualFold(expectation, continueExpectation) {
+				return wrapError(ErrExpectationFailed, expectation)
+			}
+			// RFC 9110 section 10.1.1: HTTP/1.0 clients cannot expect 100.
+			r.expectContinue = r.State != DoneState && !r.IsHTTP10()
+		}
+	}
+	return nil
+}
+
+// initBody picks the body framing following RFC 9112 section 6.3. Strict
+// mode rejects every ambiguous combination. Lenient mode lets
+// Transfer-Encoding override Content-Length and then closes the connection,
+// and accepts a Content-Length list whose values all match.
+func (r *Request) initBody() error {
+	if r.Headers.Has(transferEncodingHeader) {
+		if r.IsHTTP10() {
+			return wrapError(ErrBadRequest, "Transfer-Encoding in an HTTP/1.0 request")
+		}
+		if r.Headers.Has(contentLengthHeader) {
+			if !r.lenient {
+				return wrapError(ErrBadRequest, "both Content-Length and Transfer-Encoding present")
+			}
+			r.Headers.Del(contentLengthHeader)
+			r.closeAfter = true
+		}
+		if err := validateTransferEncoding(r.Headers.Get(transferEncodingHeader)); err != nil {
+			return err
+		}
+		r.State = ParsingChunkSizeState
+		return nil
+	}
+	values := r.Headers.Values(contentLengthHeader)
+	if len(values) == 0 {
 		// No Content-Length header means no body expected
-		// If we have data, it might be connection artifacts, so we ignore it
 		r.State = DoneState
-		return 0, nil
+		return nil
 	}
-	contentLength, err := strconv.Atoi(contentLengthString)
+	contentLength, err := r.parseContentLength(values)
 	if err != nil {
-		return 0, err
-	}
-	if len(data)+len(r.Body) > contentLength {
-		return 0, fmt.Errorf("content length is invalid")
+		return err
 	}
-	r.Body = append(r.Body, data...)
-	if len(r.Body) == contentLength {
+	r.bodyRemaining = contentLength
+	if contentLength == 0 {
 		r.State = DoneState
 	}
-	return len(data), nil
+	return r.checkBodySize(0)
 }
 
-func (r *Request) parse(data []byte) (int, error) {
-	totalBytesParsed := 0
-	for r.State != DoneState {
-		n, err := r.parseSingle(data[totalBytesParsed:])
-		if err != nil {
-			return totalBytesParsed, err
+// parseContentLength requires a single field of digits in strict mode. In
+// lenient mode repeated fields and lists are accepted when every value is
+// the same, as RFC 9110 section 8.6 allows.
+func (r *Request) parseContentLength(values []string) (int, error) {
+	if !r.lenient {
+		if len(values) > 1 {
+			return 0, wrapError(ErrBadRequest, "multiple Content-Length fields")
 		}
-		totalBytesParsed += n
-		if n == 0 {
-			break
+		return parseDigits(values[0])
+	}
+	contentLength := -1
+	for _, value := range values {
+		for _, element := range strings.Split(value, listDelimiter) {
+			n, err := parseDigits(strings.Trim(element, optionalWhitespace))
+			if err != nil {
+				return 0, err
+			}
+			if contentLength != -1 && n != contentLength {
+				return 0, wrapError(ErrBadRequest, "conflicting Content-Length values")
+			}
+			contentLength = n
 		}
 	}
-	return totalBytesParsed, nil
+	// Leave a single, plain value for handlers and proxies to read.
+	r.Headers.Set(contentLengthHeader, strconv.Itoa(contentLength))
+	return contentLength, nil
 }
 
-func parseRequestLine(RequestStr string) (*RequestLine, int, error) {
-	requestLine, consumed, err := extractRequestLineFromData(RequestStr)
+func (r *Request) parseBodyState(data []byte) (int, error) {
+	n := r.takeBody(data)
+	if r.bodyRemaining == 0 {
+		r.State = DoneState
+	}
+	return n, nil
+}
+
+func (r *Request) parseChunkSizeState(data []byte) (int, error) {
+	line, n, err := r.nextLine(data)
 	if err != nil {
-		return nil, 0, err
+		return 0, err
 	}
-	if consumed == 0 {
-		return nil, 0, nil
+	if n == 0 {
+		if len(data) > r.limits.MaxRequestLineLength {
+			return 0, wrapError(ErrBadRequest, "chunk size line too long")
+		}
+		return 0, nil
 	}
-
-	rl, err := validateAndParseRequestLine(requestLine)
+	size, err := parseChunkSize(line, r.lenient)
 	if err != nil {
-		return nil, 0, err
+		return 0, err
+	}
+	if err := r.checkBodySize(size); err != nil {
+		return 0, err
+	}
+	r.bodyRemaining = size
+	if size == 0 {
+		r.State = ParsingTrailersState
+	} else {
+		r.State = ParsingChunkDataState
 	}
+	return n, nil
+}
 
-	return rl, consumed, nil
+func (r *Request) parseChunkDataState(data []byte) (int, error) {
+	n := r.takeBody(data)
+	if r.bodyRemaining == 0 {
+		r.State = ParsingChunkDataEndState
+	}
+	return n, nil
 }
 
-func extractRequestLineFromData(data string) (string, int, error) {
-	parts := strings.Split(data, carriageReturnLineFeed)
-	if len(parts) == 1 && !strings.HasSuffix(data, carriageReturnLineFeed) {
-		return emptyString, 0, nil
+// takeBody hands out up to bodyRemaining bytes of data as the next body
+// piece. It keeps a slice of data rather than a copy; readBody copies it out
+// before the buffer is refilled.
+func (r *Request) takeBody(data []byte) int {
+	if len(data) > r.bodyRemaining {
+		data = data[:r.bodyRemaining]
 	}
-	if len(parts) < minDataPartsCount {
-		return emptyString, 0, ErrBadRequest
+	r.bodyBuffer = data
+	r.bodyRead += int64(len(data))
+	r.bodyRemaining -= len(data)
+	return len(data)
+}
+
+func (r *Request) parseChunkDataEndState(data []byte) (int, error) {
+	if r.lenient && len(data) > 0 && data[0] == lineFeed {
+		r.State = ParsingChunkSizeState
+		return 1, nil
 	}
+	if len(data) < len(carriageReturnLineFeed) {
+		return 0, nil
+	}
+	if !bytes.HasPrefix(data, []byte(carriageReturnLineFeed)) {
+		return 0, wrapError(ErrBadRequest, "chunk data not terminated by CRLF")
+	}
+	r.State = ParsingChunkSizeState
+	return len(carriageReturnLineFeed), nil
+}
 
-	requestLine := parts[requestLineDataIndex]
-	return requestLine, len(requestLine) + len(carriageReturnLineFeed), nil
+func (r *Request) parseTrailersState(data []byte) (int, error) {
+	return r.parseFieldLine(data, r.Trailers, DoneState)
 }
 
-func validateAndParseRequestLine(requestLine string) (*RequestLine, error) {
-	if err := validateRequestLineFormat(requestLine); err != nil {
-		return nil, err
+func (r *Request) parse(data []byte, until ParseState) (int, error) {
+	totalBytesParsed := 0
+	// Stop at each piece of body data so it can be copied out before the
+	// next one replaces it.
+	for r.State < until && len(r.bodyBuffer) == 0 {
+		previousState := r.State
+		n, err := r.parseSingle(data[totalBytesParsed:])
+		if err != nil {
+			return totalBytesParsed, err
+		}
+		totalBytesParsed += n
+		if n == 0 && r.State == previousState {
+			break
+		}
 	}
-
-	return parseRequestLineComponents(requestLine)
+	return totalBytesParsed, nil
 }
 
-func parseRequestLineComponents(requestLine string) (*RequestLine, error) {
+func validateAndParseRequestLine(requestLine string) (*RequestLine, error) {
 	parts, err := splitRequestLine(requestLine)
 	if err != nil {
 		return nil, err
@@ -150,6 +376,9 @@ func splitRequestLine(requestLine string) ([]string, error) {
 }
 
 func createRequestLine(parts []string) (*RequestLine, error) {
+	if !headers.IsToken(parts[requestLineMethodIndex]) {
+		return nil, wrapError(ErrBadRequest, parts[requestLineMethodIndex])
+	}
 	httpVersion, err := extractHttpVersion(parts[requestLineVersionIndex])
 	if err != nil {
 		return nil, err
@@ -163,39 +392,15 @@ func createRequestLine(parts []string) (*RequestLine, error) {
 }
 
 func extractHttpVersion(versionPart string) (string, error) {
-	parts := strings.Split(versionPart, slashDelimiter)
-	if len(parts) != httpVersionPartsCount {
-		return emptyString, ErrBadRequest
+	protocol, httpVersion, found := strings.Cut(versionPart, slashDelimiter)
+	if !found || protocol != httpProtocolName || strings.Contains(httpVersion, slashDelimiter) {
+		return emptyString, wrapError(ErrBadRequest, versionPart)
+	}
+	if !isWellFormedHttpVersion(httpVersion) {
+		return emptyString, wrapError(ErrBadRequest, versionPart)
 	}
-	httpVersion := parts[httpVersionValueIndex]
 	if !validateHttpVersion(httpVersion) {
-		return emptyString, fmt.Errorf("%s: %s", ErrBadRequest.Error(), versionPart)
+		return emptyString, wrapError(ErrVersionNotSupported, versionPart)
 	}
 	return httpVersion, nil
 }
-
-func parseRequestFromReader(reader io.Reader, buf []byte, dataBuffer []byte, r *Request) error {
-	for r.State != DoneState {
-		n, err := reader.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		if n > 0 {
-			dataBuffer = append(dataBuffer, buf[:n]...)
-		}
-		consumed, parseErr := r.parse(dataBuffer)
-		if parseErr != nil {
-			return parseErr
-		}
-		if consumed > 0 {
-			dataBuffer = dataBuffer[consumed:]
-		}
-		if err == io.EOF {
-			break
-		}
-	}
-	if r.State != DoneState {
-		return ErrBadRequest
-	}
-	return nil
-}
@@ -1,24 +1,220 @@
 package request
 
 import (
-	"fmt"
+	"context"
+	"crypto/x509"
+	"errors"
 	"io"
+	"os"
 )
 
-var ErrBadRequest = fmt.Errorf("bad request string")
+func RequestFromReader(reader io.Reader) (*Request, error) {
+	return NewReader(reader).ReadRequest()
+}
 
-const (
-	bufferSize = 8
-)
+// NewReader returns a Reader whose buffer is reused by every request read
+// from it.
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{
+		reader: reader,
+		buf:    make([]byte, readBufferSize),
+		limits: DefaultLimits(),
+	}
+}
 
-func RequestFromReader(reader io.Reader) (*Request, error) {
-	buf := make([]byte, bufferSize)
-	dataBuffer := make([]byte, 0)
-	r := newRequest()
-	err := parseRequestFromReader(reader, buf, dataBuffer, &r)
-	if err != nil {
-		return nil, err
+func (rd *Reader) SetLimits(limits Limits) {
+	rd.limits = limits.withDefaults()
+}
+
+// SetLenient relaxes the strict RFC 9112 parsing for legacy clients: bare LF
+// line endings, obs-fold, empty lines before the request-line, repeated
+// identical Content-Length values and Content-Length alongside
+// Transfer-Encoding are accepted. Lenient requests that carried both
+// framings are not kept alive.
+func (rd *Reader) SetLenient(lenient bool) {
+	rd.lenient = lenient
+}
+
+func (rd *Reader) ReadRequest() (*Request, error) {
+	if rd.current != nil {
+		if err := rd.current.DiscardBody(unlimitedDiscard); err != nil {
+			return nil, err
+		}
+		rd.current = nil
 	}
 
+	r := newRequest(rd.limits)
+	r.lenient = rd.lenient
+	if err := rd.readHead(&r); err != nil {
+		return nil, err
+	}
+	if err := r.initHost(); err != nil {
+		return nil, err
+	}
+	if err := r.initBody(); err != nil {
+		return nil, err
+	}
+	if err := r.initExpect(); err != nil {
+		return nil, err
+	}
+	r.body = &body{reader: rd, req: &r}
+	r.Body = r.body
+	rd.current = &r
 	return &r, nil
 }
+
+func (rd *Reader) Buffered() int {
+	return rd.end - rd.start
+}
+
+func (rd *Reader) readHead(r *Request) error {
+	for {
+		consumed, err := r.parse(rd.buf[rd.start:rd.end], ParsingBodyState)
+		rd.start += consumed
+		if err != nil {
+			return err
+		}
+		if r.State >= ParsingBodyState {
+			return nil
+		}
+		if err := rd.fill(r); err != nil {
+			return err
+		}
+	}
+}
+
+// readBody copies body bytes into p. The parser hands out body data as a
+// slice of the read buffer, which stays valid because fill only runs once it
+// has been copied out.
+func (rd *Reader) readBody(r *Request, p []byte) (int, error) {
+	for len(r.bodyBuffer) == 0 {
+		if r.bodyErr != nil {
+			return 0, r.bodyErr
+		}
+		if r.State == DoneState {
+			return 0, io.EOF
+		}
+		consumed, err := r.parse(rd.buf[rd.start:rd.end], DoneState)
+		rd.start += consumed
+		if err == nil && consumed == 0 {
+			err = rd.fill(r)
+		}
+		if err != nil {
+			r.bodyErr = err
+		}
+	}
+	n := copy(p, r.bodyBuffer)
+	r.bodyBuffer = r.bodyBuffer[n:]
+	return n, nil
+}
+
+// fill reads more input after the unconsumed bytes, first moving them to the
+// front of the buffer and growing it only when they already fill it.
+func (rd *Reader) fill(r *Request) error {
+	if rd.start > 0 {
+		rd.end = copy(rd.buf, rd.buf[rd.start:rd.end])
+		rd.start = 0
+	}
+	if rd.end == len(rd.buf) {
+		grown := make([]byte, 2*len(rd.buf))
+		copy(grown, rd.buf[:rd.end])
+		rd.buf = grown
+	}
+	n, err := rd.reader.Read(rd.buf[rd.end:])
+	rd.end += n
+	if n > 0 || err == nil {
+		return nil
+	}
+	started := r.State != PendingState || rd.Buffered() > 0
+	switch {
+	case errors.Is(err, io.EOF) && !started:
+		return io.EOF
+	case errors.Is(err, io.EOF):
+		return wrapError(ErrBadRequest, io.ErrUnexpectedEOF)
+	case errors.Is(err, os.ErrDeadlineExceeded) && started:
+		return wrapError(ErrRequestTimeout, err)
+	default:
+		return err
+	}
+}
+
+// KeepAlive reports whether the client wants the connection kept open:
+// HTTP/1.1 persists unless asked to close, HTTP/1.0 only when asked to.
+func (r *Request) KeepAlive() bool {
+	if r.closeAfter || r.Headers.HasToken(connectionHeader, closeConnectionToken) {
+		return false
+	}
+	if r.IsHTTP10() {
+		return r.Headers.HasToken(connectionHeader, keepAliveToken)
+	}
+	return true
+}
+
+// IsHTTP10 reports whether the request uses HTTP/1.0 semantics. Any later
+// 1.x minor version is treated as HTTP/1.1.
+func (r *Request) IsHTTP10() bool {
+	return r.RequestLine.HttpVersion == httpVersion10
+}
+
+// ExpectsContinue reports whether the client sent "Expect: 100-continue" and
+// is still waiting for the interim response before sending the body.
+func (r *Request) ExpectsContinue() bool {
+	r.continueMu.Lock()
+	defer r.continueMu.Unlock()
+	return r.expectContinue
+}
+
+// OnContinue registers the function that sends "100 Continue". It runs once,
+// on the first read of the body.
+func (r *Request) OnContinue(fn func() error) {
+	r.continueMu.Lock()
+	defer r.continueMu.Unlock()
+	r.onContinue = fn
+}
+
+// CancelContinue makes sure "100 Continue" is never sent from now on, for
+// middleware that answers while the handler may still read the body on
+// another goroutine. It waits for an interim response already being written.
+func (r *Request) CancelContinue() {
+	r.continueMu.Lock()
+	defer r.continueMu.Unlock()
+	r.onContinue = nil
+}
+
+func (r *Request) sendContinue() error {
+	r.continueMu.Lock()
+	defer r.continueMu.Unlock()
+	if !r.expectContinue {
+		return nil
+	}
+	r.expectContinue = false
+	if r.onContinue == nil {
+		return nil
+	}
+	return r.onContinue()
+}
+
+// VerifiedChain returns the client certificate chain, leaf first, as
+// verified during the TLS handshake. It is nil when the connection is not
+// TLS or the client sent no certificate that was verified.
+func (r *Request) VerifiedChain() []*x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0]
+}
+
+func (r *Request) Param(name string) string {
+	return r.Params[name]
+}
+
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+func (r *Request) SetContext(ctx context.Context) {
+	r.ctx = ctx
+}
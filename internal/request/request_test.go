@@ -1,7 +1,9 @@
 package request
 
 import (
+	"fmt"
 	"io"
+	"os"
 	"strings"
 	"testing"
 
@@ -34,6 +36,22 @@ func (cr *chunkReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+func readAllBody(t *testing.T, r *Request) string {
+	t.Helper()
+	data, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func readFullRequest(reader io.Reader) (*Request, []byte, error) {
+	r, err := RequestFromReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(r.Body)
+	return r, data, err
+}
+
 func TestGoodGETRequestLine(t *testing.T) {
 	reader := &chunkReader{
 		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nUser-Agent: curl/7.81.0\r\nAccept: */*\r\n\r\n",
@@ -73,9 +91,9 @@ func TestStandardHeaders(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, "localhost:42069", r.Headers.Get("host"))
+	assert.Equal(t, "curl/7.81.0", r.Headers.Get("user-agent"))
+	assert.Equal(t, "*/*", r.Headers.Get("accept"))
 }
 
 func TestMalformedHeader(t *testing.T) {
@@ -92,19 +110,20 @@ func TestEmptyHeaders(t *testing.T) {
 		data:            "GET / HTTP/1.1\r\n\r\n",
 		numBytesPerRead: 2,
 	}
-	r, err := RequestFromReader(reader)
-	require.NoError(t, err)
-	assert.Empty(t, r.Headers)
+	// HTTP/1.1 requires a Host field, so an empty field section is rejected
+	_, err := RequestFromReader(reader)
+	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestDuplicateHeaders(t *testing.T) {
 	reader := &chunkReader{
-		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nHost: example.com\r\n\r\n",
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nAccept: text/html\r\nAccept: application/json\r\n\r\n",
 		numBytesPerRead: 1,
 	}
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
-	assert.Equal(t, "localhost:42069, example.com", r.Headers["host"])
+	assert.Equal(t, "text/html, application/json", r.Headers.Get("accept"))
+	assert.Equal(t, []string{"text/html", "application/json"}, r.Headers.Values("Accept"))
 }
 
 func TestCaseInsensitiveHeaders(t *testing.T) {
@@ -114,7 +133,7 @@ func TestCaseInsensitiveHeaders(t *testing.T) {
 	}
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
+	assert.Equal(t, "localhost:42069", r.Headers.Get("host"))
 }
 
 func TestMissingEndOfHeaders(t *testing.T) {
@@ -127,13 +146,17 @@ func TestMissingEndOfHeaders(t *testing.T) {
 }
 
 func TestHeaderWithLeadingTrailingSpaces(t *testing.T) {
-	reader := &chunkReader{
-		data:            "GET / HTTP/1.1\r\n Host: localhost:42069 \r\n\r\n",
-		numBytesPerRead: 1,
-	}
-	r, err := RequestFromReader(reader)
+	data := "GET / HTTP/1.1\r\n Host: localhost:42069 \r\n\r\n"
+
+	// Strict mode treats the leading space as obs-fold
+	_, err := RequestFromReader(&chunkReader{data: data, numBytesPerRead: 1})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	reader := NewReader(&chunkReader{data: data, numBytesPerRead: 1})
+	reader.SetLenient(true)
+	r, err := reader.ReadRequest()
 	require.NoError(t, err)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
+	assert.Equal(t, "localhost:42069", r.Headers.Get("host"))
 }
 
 func TestVeryLongHeaderValue(t *testing.T) {
@@ -144,7 +167,7 @@ func TestVeryLongHeaderValue(t *testing.T) {
 	}
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
-	assert.Equal(t, longValue, r.Headers["host"])
+	assert.Equal(t, longValue, r.Headers.Get("host"))
 }
 
 func TestStandardBody(t *testing.T) {
@@ -160,7 +183,7 @@ func TestStandardBody(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "hello world!\n", string(r.Body))
+	assert.Equal(t, "hello world!\n", readAllBody(t, r))
 }
 
 func TestBodyShorterThanContentLength(t *testing.T) {
@@ -173,6 +196,738 @@ func TestBodyShorterThanContentLength(t *testing.T) {
 			"partial content",
 		numBytesPerRead: 3,
 	}
-	_, err := RequestFromReader(reader)
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.Body)
 	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestEmptyReaderReturnsEOF(t *testing.T) {
+	_, err := RequestFromReader(strings.NewReader(""))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestKeepAlive(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection string
+		expected   bool
+	}{
+		{"No Connection header", "", true},
+		{"Keep-alive", "Connection: keep-alive\r\n", true},
+		{"Close", "Connection: close\r\n", false},
+		{"Close in token list", "Connection: Upgrade, CLOSE\r\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\n" + tt.connection + "\r\n"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.KeepAlive())
+		})
+	}
+}
+
+func TestReaderMultipleRequests(t *testing.T) {
+	reader := NewReader(&chunkReader{
+		data: "POST /one HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\n\r\nabc" +
+			"GET /two HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+			"GET /three HTTP/1.1\r\nHost: localhost\r\n\r\n",
+		numBytesPerRead: 7,
+	})
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/one", r.RequestLine.RequestTarget)
+	assert.Equal(t, "abc", readAllBody(t, r))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/three", r.RequestLine.RequestTarget)
+	assert.Equal(t, 0, reader.Buffered())
+
+	_, err = reader.ReadRequest()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+type timeoutReader struct {
+	data string
+	read bool
+}
+
+func (tr *timeoutReader) Read(p []byte) (int, error) {
+	if tr.read {
+		return 0, os.ErrDeadlineExceeded
+	}
+	tr.read = true
+	return copy(p, tr.data), nil
+}
+
+func TestParseErrorStatusCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		reader   io.Reader
+		sentinel *ParseError
+		status   int
+	}{
+		{"Bad request line", strings.NewReader("GET /\r\n\r\n"), ErrBadRequest, 400},
+		{"Malformed version", strings.NewReader("GET / HTTX/1.1\r\n\r\n"), ErrBadRequest, 400},
+		{"Unsupported version", strings.NewReader("GET / HTTP/2.0\r\n\r\n"), ErrVersionNotSupported, 505},
+		{"Request line too long", strings.NewReader("GET /" + strings.Repeat("a", defaultMaxRequestLineLength) + " HTTP/1.1\r\nHost: localhost\r\n\r\n"), ErrURITooLong, 414},
+		{"Unterminated request line too long", strings.NewReader(strings.Repeat("a", defaultMaxRequestLineLength+1)), ErrURITooLong, 414},
+		{"Headers too large", strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", defaultMaxHeaderBytes)), ErrHeaderFieldsTooLarge, 431},
+		{"Unknown transfer coding", strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip\r\n\r\n"), ErrNotImplemented, 501},
+		{"Invalid content length", strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: abc\r\n\r\n"), ErrBadRequest, 400},
+		{"Read timeout", &timeoutReader{data: "GET / HTTP/1.1\r\nHost: localhost\r\n"}, ErrRequestTimeout, 408},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RequestFromReader(tt.reader)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.sentinel)
+			assert.Equal(t, tt.status, StatusCode(err))
+		})
+	}
+}
+
+func TestIdleTimeoutIsNotRequestTimeout(t *testing.T) {
+	_, err := RequestFromReader(&timeoutReader{read: true})
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrRequestTimeout)
+}
+
+func TestChunkedBody(t *testing.T) {
+	for _, bytesPerRead := range []int{1, 3, 64} {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\nhello\r\n" +
+				"7;name=value;flag\r\n, world\r\n" +
+				"1A \r\n" + strings.Repeat("x", 26) + "\r\n" +
+				"0\r\n" +
+				"X-Checksum: abc123\r\n" +
+				"X-Count: 3\r\n" +
+				"\r\n",
+			numBytesPerRead: bytesPerRead,
+		}
+		r, body, err := readFullRequest(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello, world"+strings.Repeat("x", 26), string(body))
+		assert.Equal(t, "abc123", r.Trailers.Get("x-checksum"))
+		assert.Equal(t, "3", r.Trailers.Get("x-count"))
+		assert.False(t, r.Headers.Has("X-Checksum"))
+	}
+}
+
+func TestChunkedBodyWithoutTrailers(t *testing.T) {
+	reader := NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3\r\nabc\r\n0\r\n\r\n" +
+		"GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", readAllBody(t, r))
+	assert.Equal(t, 0, r.Trailers.Len())
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", r.RequestLine.RequestTarget)
+}
+
+func TestInvalidChunkedBodies(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		sentinel *ParseError
+	}{
+		{"Content-Length and Transfer-Encoding", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n", ErrBadRequest},
+		{"Chunked applied twice", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked, chunked\r\n\r\n0\r\n\r\n", ErrBadRequest},
+		{"Unsupported coding before chunked", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip, chunked\r\n\r\n0\r\n\r\n", ErrNotImplemented},
+		{"Invalid chunk size", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\nabc\r\n0\r\n\r\n", ErrBadRequest},
+		{"Empty chunk size", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n;ext\r\n0\r\n\r\n", ErrBadRequest},
+		{"Negative chunk size", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n-1\r\nabc\r\n0\r\n\r\n", ErrBadRequest},
+		{"Oversized chunk size", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\nffffffffffffffffff\r\nabc\r\n0\r\n\r\n", ErrBadRequest},
+		{"Chunk data too long", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nabc\r\n0\r\n\r\n", ErrBadRequest},
+		{"Missing last chunk", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n", ErrBadRequest},
+		{"Malformed trailer", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nX-Bad Trailer\r\n\r\n", ErrBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readFullRequest(strings.NewReader(tt.request))
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.sentinel)
+		})
+	}
+}
+
+func TestBodyIsStreamed(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("POST /stream HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\n"))
+	}()
+
+	r, err := RequestFromReader(pr)
+	require.NoError(t, err)
+	assert.Equal(t, "/stream", r.RequestLine.RequestTarget)
+
+	go func() {
+		_, _ = pw.Write([]byte("first"))
+		_, _ = pw.Write([]byte("secon"))
+	}()
+	assert.Equal(t, "firstsecon", readAllBody(t, r))
+}
+
+func TestUnreadBodyIsDiscarded(t *testing.T) {
+	reader := NewReader(&chunkReader{
+		data: "POST /one HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\nhello world" +
+			"POST /two HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n" +
+			"GET /three HTTP/1.1\r\nHost: localhost\r\n\r\n",
+		numBytesPerRead: 5,
+	})
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/one", r.RequestLine.RequestTarget)
+	buf := make([]byte, 3)
+	_, err = r.Body.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, r.Body.Close())
+	_, err = r.Body.Read(buf)
+	assert.ErrorIs(t, err, ErrBodyClosed)
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/three", r.RequestLine.RequestTarget)
+	assert.Empty(t, readAllBody(t, r))
+}
+
+func TestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineLength: 32,
+		MaxHeaderBytes:       64,
+		MaxHeaderCount:       3,
+		MaxBodyBytes:         8,
+	}
+	tests := []struct {
+		name     string
+		request  string
+		sentinel *ParseError
+	}{
+		{"Request line at limit", "GET /" + strings.Repeat("a", 18) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", nil},
+		{"Request line over limit", "GET /" + strings.Repeat("a", 19) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrURITooLong},
+		{"Header bytes over limit", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Long: " + strings.Repeat("a", 64) + "\r\n\r\n", ErrHeaderFieldsTooLarge},
+		{"Header count at limit", "GET / HTTP/1.1\r\nHost: localhost\r\nA: 1\r\nB: 2\r\n\r\n", nil},
+		{"Header count over limit", "GET / HTTP/1.1\r\nHost: localhost\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n", ErrHeaderFieldsTooLarge},
+		{"Content-Length at limit", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 8\r\n\r\n12345678", nil},
+		{"Content-Length over limit", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 9\r\n\r\n123456789", ErrContentTooLarge},
+		{"Chunked body over limit", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n5\r\n12345\r\n4\r\n6789\r\n0\r\n\r\n", ErrContentTooLarge},
+		{"Trailer count over limit", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\n\r\n", ErrHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(&chunkReader{data: tt.request, numBytesPerRead: 4})
+			reader.SetLimits(limits)
+			r, err := reader.ReadRequest()
+			if err == nil {
+				_, err = io.ReadAll(r.Body)
+			}
+			if tt.sentinel == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.sentinel)
+		})
+	}
+}
+
+func TestEndlessHeaderIsRejected(t *testing.T) {
+	endless := io.MultiReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\nX-Endless: "), &repeatReader{})
+	_, err := RequestFromReader(endless)
+	assert.ErrorIs(t, err, ErrHeaderFieldsTooLarge)
+}
+
+type repeatReader struct{}
+
+func (repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func TestLimitBody(t *testing.T) {
+	t.Run("Content-Length over route limit", func(t *testing.T) {
+		r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\n0123456789"))
+		require.NoError(t, err)
+		err = r.LimitBody(5)
+		assert.ErrorIs(t, err, ErrContentTooLarge)
+		_, err = io.ReadAll(r.Body)
+		assert.ErrorIs(t, err, ErrContentTooLarge)
+	})
+
+	t.Run("Chunked body over route limit", func(t *testing.T) {
+		r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nabcd\r\n4\r\nefgh\r\n0\r\n\r\n"))
+		require.NoError(t, err)
+		require.NoError(t, r.LimitBody(6))
+		data, err := io.ReadAll(r.Body)
+		assert.ErrorIs(t, err, ErrContentTooLarge)
+		assert.Equal(t, "abcd", string(data))
+	})
+
+	t.Run("Body within route limit", func(t *testing.T) {
+		r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\n\r\nabc"))
+		require.NoError(t, err)
+		require.NoError(t, r.LimitBody(3))
+		assert.Equal(t, "abc", readAllBody(t, r))
+	})
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		raw      string
+		expected Target
+	}{
+		{
+			name:   "origin-form",
+			method: "GET",
+			raw:    "/a%20b/c%2Fd?x=1&y=%C3%A9&x=2&flag&&z=a+b",
+			expected: Target{
+				Form:     OriginForm,
+				RawPath:  "/a%20b/c%2Fd",
+				Path:     "/a b/c/d",
+				RawQuery: "x=1&y=%C3%A9&x=2&flag&&z=a+b",
+				Query:    Query{"x": {"1", "2"}, "y": {"é"}, "flag": {""}, "z": {"a+b"}},
+			},
+		},
+		{
+			name:   "absolute-form",
+			method: "GET",
+			raw:    "HTTP://example.com:8080/path?q=1",
+			expected: Target{
+				Form:      AbsoluteForm,
+				Scheme:    "http",
+				Authority: "example.com:8080",
+				RawPath:   "/path",
+				Path:      "/path",
+				RawQuery:  "q=1",
+				Query:     Query{"q": {"1"}},
+			},
+		},
+		{
+			name:   "absolute-form with empty path",
+			method: "GET",
+			raw:    "http://[::1]?q",
+			expected: Target{
+				Form:      AbsoluteForm,
+				Scheme:    "http",
+				Authority: "[::1]",
+				RawPath:   "/",
+				Path:      "/",
+				RawQuery:  "q",
+				Query:     Query{"q": {""}},
+			},
+		},
+		{
+			name:     "authority-form",
+			method:   "CONNECT",
+			raw:      "example.com:443",
+			expected: Target{Form: AuthorityForm, Authority: "example.com:443", Query: Query{}},
+		},
+		{
+			name:     "asterisk-form",
+			method:   "OPTIONS",
+			raw:      "*",
+			expected: Target{Form: AsteriskForm, RawPath: "*", Path: "*", Query: Query{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ParseTarget(tt.method, tt.raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, target)
+		})
+	}
+}
+
+func TestParseTargetErrors(t *testing.T) {
+	tests := []struct {
+		method string
+		raw    string
+	}{
+		{"GET", "/path#fragment"},
+		{"GET", "/bad%zzescape"},
+		{"GET", "/trailing%2"},
+		{"GET", "/space here"},
+		{"GET", "/quote\"d"},
+		{"GET", "/q?bad|char"},
+		{"GET", "*"},
+		{"GET", "example.com:443"},
+		{"GET", "http:/missing-slash"},
+		{"GET", "1http://example.com/"},
+		{"GET", "http://user@example.com/"},
+		{"GET", "http:///path"},
+		{"GET", "http://example.com:99999/"},
+		{"GET", "http://[::1/"},
+		{"CONNECT", "example.com"},
+		{"CONNECT", "/path"},
+		{"CONNECT", "example.com:443/path"},
+		{"CONNECT", "example.com:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.raw, func(t *testing.T) {
+			_, err := ParseTarget(tt.method, tt.raw)
+			require.Error(t, err)
+			assert.Equal(t, 400, StatusCode(err))
+		})
+	}
+}
+
+func TestRequestTarget(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("GET /search?q=go&q=http HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/search?q=go&q=http", r.RequestLine.RequestTarget)
+	assert.Equal(t, "/search", r.Target.Path)
+	assert.Equal(t, []string{"go", "http"}, r.Target.Query["q"])
+	assert.Equal(t, "go", r.Target.Query.Get("q"))
+	assert.Equal(t, []string{"search"}, r.Target.Segments())
+
+	_, err = RequestFromReader(strings.NewReader("GET /page#top HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestHostValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+		err      error
+	}{
+		{"Host header", "GET / HTTP/1.1\r\nHost: example.test:8080\r\n\r\n", "example.test:8080", nil},
+		{"IPv6 host", "GET / HTTP/1.1\r\nHost: [::1]:80\r\n\r\n", "[::1]:80", nil},
+		{"Empty host", "GET / HTTP/1.1\r\nHost: \r\n\r\n", "", nil},
+		{"Absolute-form overrides Host", "GET http://target.test/ HTTP/1.1\r\nHost: other.test\r\n\r\n", "target.test", nil},
+		{"Authority-form", "CONNECT proxy.test:443 HTTP/1.1\r\nHost: proxy.test:443\r\n\r\n", "proxy.test:443", nil},
+		{"Missing Host", "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", "", ErrBadRequest},
+		{"Duplicate Host", "GET / HTTP/1.1\r\nHost: a.test\r\nHost: a.test\r\n\r\n", "", ErrBadRequest},
+		{"Host with userinfo", "GET / HTTP/1.1\r\nHost: user@a.test\r\n\r\n", "", ErrBadRequest},
+		{"Host with path", "GET / HTTP/1.1\r\nHost: a.test/path\r\n\r\n", "", ErrBadRequest},
+		{"Host with bad port", "GET / HTTP/1.1\r\nHost: a.test:http\r\n\r\n", "", ErrBadRequest},
+		{"Host with list", "GET / HTTP/1.1\r\nHost: a.test, b.test\r\n\r\n", "", ErrBadRequest},
+		{"Host with bad escape", "GET / HTTP/1.1\r\nHost: %zz\r\n\r\n", "", ErrBadRequest},
+		{"Host with short escape", "GET / HTTP/1.1\r\nHost: a.test%4\r\n\r\n", "", ErrBadRequest},
+		{"Host with escape", "GET / HTTP/1.1\r\nHost: a%2Db.test\r\n\r\n", "a%2Db.test", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := RequestFromReader(strings.NewReader(tt.request))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.Host)
+		})
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected bool
+		err      error
+	}{
+		{"100-continue with body", "POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-Continue\r\nContent-Length: 4\r\n\r\ndata", true, nil},
+		{"100-continue without body", "GET / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\n\r\n", false, nil},
+		{"No expectation", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\n\r\ndata", false, nil},
+		{"Unknown expectation", "POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 200-ok\r\nContent-Length: 4\r\n\r\ndata", false, ErrExpectationFailed},
+		{"Unknown in list", "POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue, later\r\nContent-Length: 4\r\n\r\ndata", false, ErrExpectationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := RequestFromReader(strings.NewReader(tt.request))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, 417, StatusCode(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.ExpectsContinue())
+		})
+	}
+}
+
+func TestOnContinue(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 11\r\n\r\nhello world"))
+	require.NoError(t, err)
+
+	calls := 0
+	r.OnContinue(func() error {
+		calls++
+		return nil
+	})
+	assert.Equal(t, 0, calls)
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+	assert.Equal(t, 1, calls)
+	assert.False(t, r.ExpectsContinue())
+}
+
+func TestOnContinue_SkippedWhenBodyRejected(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 11\r\n\r\nhello world"))
+	require.NoError(t, err)
+	r.OnContinue(func() error {
+		t.Fatal("100 Continue sent for a rejected body")
+		return nil
+	})
+
+	assert.ErrorIs(t, r.LimitBody(4), ErrContentTooLarge)
+	assert.False(t, r.ExpectsContinue())
+	_, err = r.Body.Read(make([]byte, 4))
+	assert.ErrorIs(t, err, ErrContentTooLarge)
+}
+
+func TestCancelContinue(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 4\r\n\r\ndata"))
+	require.NoError(t, err)
+	r.OnContinue(func() error {
+		t.Fatal("100 Continue sent after CancelContinue")
+		return nil
+	})
+
+	r.CancelContinue()
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(body))
+	assert.False(t, r.ExpectsContinue())
+}
+
+func TestHTTPVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		http10    bool
+		keepAlive bool
+		err       error
+	}{
+		{"HTTP/1.1", "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n", false, true, nil},
+		{"HTTP/1.0 without Host", "GET / HTTP/1.0\r\n\r\n", true, false, nil},
+		{"HTTP/1.0 keep-alive", "GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n", true, true, nil},
+		{"HTTP/1.1 close", "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n", false, false, nil},
+		{"Higher minor version", "GET / HTTP/1.2\r\nHost: localhost\r\n\r\n", false, true, nil},
+		{"Higher minor version needs Host", "GET / HTTP/1.2\r\n\r\n", false, false, ErrBadRequest},
+		{"HTTP/2.0", "GET / HTTP/2.0\r\nHost: localhost\r\n\r\n", false, false, ErrVersionNotSupported},
+		{"HTTP/0.9", "GET / HTTP/0.9\r\n\r\n", false, false, ErrVersionNotSupported},
+		{"HTTP/1.0 chunked", "POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", false, false, ErrBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := RequestFromReader(strings.NewReader(tt.request))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.http10, r.IsHTTP10())
+			assert.Equal(t, tt.keepAlive, r.KeepAlive())
+		})
+	}
+}
+
+func TestExpectContinueIgnoredForHTTP10(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nExpect: 100-continue\r\nContent-Length: 4\r\n\r\ndata"))
+	require.NoError(t, err)
+	assert.False(t, r.ExpectsContinue())
+}
+
+const benchmarkRequest = "GET /api/items?page=2 HTTP/1.1\r\n" +
+	"Host: localhost:42069\r\n" +
+	"User-Agent: curl/7.81.0\r\n" +
+	"Accept: */*\r\n" +
+	"Accept-Encoding: gzip, deflate\r\n" +
+	"Connection: keep-alive\r\n" +
+	"\r\n"
+
+func BenchmarkReadRequest(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkRequest)))
+	for b.Loop() {
+		if _, err := RequestFromReader(strings.NewReader(benchmarkRequest)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadRequestLargeHeaders(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("GET / HTTP/1.1\r\nHost: localhost\r\n")
+	for i := range 90 {
+		fmt.Fprintf(&sb, "X-Header-%d: %s\r\n", i, strings.Repeat("v", 512))
+	}
+	sb.WriteString("\r\n")
+	data := sb.String()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := RequestFromReader(strings.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReadRequestPipelined reads many requests through one Reader, as a
+// keep-alive connection does.
+func BenchmarkReadRequestPipelined(b *testing.B) {
+	const perConnection = 100
+	data := strings.Repeat(benchmarkRequest, perConnection)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		reader := NewReader(strings.NewReader(data))
+		for range perConnection {
+			if _, err := reader.ReadRequest(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadChunkedBody(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n")
+	for range 64 {
+		fmt.Fprintf(&sb, "400\r\n%s\r\n", strings.Repeat("b", 1024))
+	}
+	sb.WriteString("0\r\n\r\n")
+	data := sb.String()
+	scratch := make([]byte, 32*1024)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		r, err := RequestFromReader(strings.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			_, err := r.Body.Read(scratch)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestInvalidFieldValues(t *testing.T) {
+	for _, value := range []string{"a\x00b", "a\rb", "a\nb"} {
+		_, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\nX-Test: " + value + "\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrBadRequest, "value %q", value)
+	}
+}
+
+// TestSmugglingCorpus runs known request smuggling payloads through both
+// parsing modes. A nil error means the request parses and its body reads back
+// as body.
+func TestSmugglingCorpus(t *testing.T) {
+	const head = "POST / HTTP/1.1\r\nHost: localhost\r\n"
+	tests := []struct {
+		name       string
+		request    string
+		strictErr  *ParseError
+		lenientErr *ParseError
+		body       string
+		keepAlive  bool
+	}{
+		{"CL.TE", head + "Content-Length: 6\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\nG", ErrBadRequest, nil, "", false},
+		{"TE.CL", head + "Transfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\n8\r\nSMUGGLED\r\n0\r\n\r\n", ErrBadRequest, nil, "SMUGGLED", false},
+		{"Conflicting Content-Length fields", head + "Content-Length: 5\r\nContent-Length: 6\r\n\r\nhello!", ErrBadRequest, ErrBadRequest, "", false},
+		{"Identical Content-Length fields", head + "Content-Length: 5\r\nContent-Length: 5\r\n\r\nhello", ErrBadRequest, nil, "hello", true},
+		{"Content-Length list", head + "Content-Length: 5, 5\r\n\r\nhello", ErrBadRequest, nil, "hello", true},
+		{"Conflicting Content-Length list", head + "Content-Length: 5, 6\r\n\r\nhello!", ErrBadRequest, ErrBadRequest, "", false},
+		{"Negative Content-Length", head + "Content-Length: -1\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Signed Content-Length", head + "Content-Length: +5\r\n\r\nhello", ErrBadRequest, ErrBadRequest, "", false},
+		{"Hex Content-Length", head + "Content-Length: 0x5\r\n\r\nhello", ErrBadRequest, ErrBadRequest, "", false},
+		{"Empty Content-Length", head + "Content-Length: \r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Overflowing Content-Length", head + "Content-Length: 99999999999999999999\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Chunked not final", head + "Transfer-Encoding: chunked, identity\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Chunked twice", head + "Transfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Obfuscated coding", head + "Transfer-Encoding: xchunked\r\n\r\n", ErrNotImplemented, ErrNotImplemented, "", false},
+		{"Empty Transfer-Encoding", head + "Transfer-Encoding: \r\nContent-Length: 5\r\n\r\nhello", ErrBadRequest, ErrBadRequest, "", false},
+		{"Space before colon", head + "Transfer-Encoding : chunked\r\n\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Tab before colon", head + "Transfer-Encoding\t: chunked\r\n\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Folded Transfer-Encoding", head + "Transfer-Encoding:\r\n chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", ErrBadRequest, nil, "hello", true},
+		{"Bare LF line endings", "POST / HTTP/1.1\nHost: localhost\nContent-Length: 5\n\nhello", ErrBadRequest, nil, "hello", true},
+		{"Bare LF after chunk", head + "Transfer-Encoding: chunked\r\n\r\n5\r\nhello\n0\r\n\r\n", ErrBadRequest, nil, "hello", true},
+		{"Bare CR in field value", head + "X-Test: a\rb\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"NUL in field value", head + "X-Test: a\x00b\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Signed chunk size", head + "Transfer-Encoding: chunked\r\n\r\n+5\r\nhello\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Hex-prefixed chunk size", head + "Transfer-Encoding: chunked\r\n\r\n0x5\r\nhello\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Overflowing chunk size", head + "Transfer-Encoding: chunked\r\n\r\nffffffffffffffffff\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Chunked HTTP/1.0", "POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Control character in chunk extension", head + "Transfer-Encoding: chunked\r\n\r\n5;\x01ext\r\nhello\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Bare CR in chunk extension", head + "Transfer-Encoding: chunked\r\n\r\n5;a\rb\r\nhello\r\n0\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Malformed chunk extension", head + "Transfer-Encoding: chunked\r\n\r\n5;=x\r\nhello\r\n0\r\n\r\n", ErrBadRequest, nil, "hello", true},
+		{"Unterminated quoted chunk extension", head + "Transfer-Encoding: chunked\r\n\r\n5;a=\"b\r\nhello\r\n0\r\n\r\n", ErrBadRequest, nil, "hello", true},
+		{"Valid chunk extensions", head + "Transfer-Encoding: chunked\r\n\r\n5 ; a = b ;flag;q=\"x\\\"; y\"\r\nhello\r\n0;last\r\n\r\n", nil, nil, "hello", true},
+		{"Bare CR in method", "G\rET / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Bare CR in target", "GET /a\rb HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Bare CR before line ending", "GET / HTTP/1.1\r\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Delimiter in method", "GE(T / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Control character in method", "G\x01ET / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Empty method", " / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrBadRequest, ErrBadRequest, "", false},
+		{"Empty line before request", "\r\n" + head + "Content-Length: 5\r\n\r\nhello", nil, nil, "hello", true},
+		{"Two empty lines before request", "\r\n\r\n" + head + "Content-Length: 5\r\n\r\nhello", ErrBadRequest, nil, "hello", true},
+	}
+	for _, tt := range tests {
+		for _, lenient := range []bool{false, true} {
+			expected := tt.strictErr
+			mode := "strict"
+			if lenient {
+				expected = tt.lenientErr
+				mode = "lenient"
+			}
+			t.Run(tt.name+"/"+mode, func(t *testing.T) {
+				reader := NewReader(&chunkReader{data: tt.request, numBytesPerRead: 3})
+				reader.SetLenient(lenient)
+				r, err := reader.ReadRequest()
+				var body []byte
+				if err == nil {
+					body, err = io.ReadAll(r.Body)
+				}
+				if expected != nil {
+					assert.ErrorIs(t, err, expected)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+				assert.Equal(t, tt.keepAlive, r.KeepAlive())
+				assert.LessOrEqual(t, len(r.Headers.Values("Content-Length")), 1)
+			})
+		}
+	}
 }
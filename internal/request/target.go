@@ -0,0 +1,185 @@
+package request
+
+import "strings"
+
+// ParseTarget parses a request-target in one of the four forms of RFC 9112
+// section 3.2. The form is chosen by method and shape: authority-form only
+// for CONNECT, asterisk-form only for OPTIONS.
+func ParseTarget(method, raw string) (Target, error) {
+	if raw == emptyString {
+		return Target{}, wrapError(ErrBadRequest, "empty request-target")
+	}
+	if strings.Contains(raw, fragmentDelimiter) {
+		return Target{}, wrapError(ErrBadRequest, "fragment in request-target")
+	}
+
+	switch {
+	case method == methodConnect:
+		if err := validateAuthority(raw, true); err != nil {
+			return Target{}, err
+		}
+		return Target{Form: AuthorityForm, Authority: raw, Query: Query{}}, nil
+	case raw == asteriskTarget:
+		if method != methodOptions {
+			return Target{}, wrapError(ErrBadRequest, "asterisk-form is only allowed for OPTIONS")
+		}
+		return Target{Form: AsteriskForm, RawPath: raw, Path: raw, Query: Query{}}, nil
+	case strings.HasPrefix(raw, rootPath):
+		target := Target{Form: OriginForm}
+		return target, target.parsePathAndQuery(raw)
+	default:
+		return parseAbsoluteForm(raw)
+	}
+}
+
+func parseAbsoluteForm(raw string) (Target, error) {
+	scheme, rest, ok := strings.Cut(raw, schemeDelimiter)
+	if !ok || !isValidScheme(scheme) {
+		return Target{}, wrapError(ErrBadRequest, raw)
+	}
+	authorityEnd := strings.IndexAny(rest, rootPath+queryDelimiter)
+	if authorityEnd == -1 {
+		authorityEnd = len(rest)
+	}
+	authority := rest[:authorityEnd]
+	if err := validateAuthority(authority, false); err != nil {
+		return Target{}, err
+	}
+
+	target := Target{Form: AbsoluteForm, Scheme: strings.ToLower(scheme), Authority: authority}
+	pathAndQuery := rest[authorityEnd:]
+	if !strings.HasPrefix(pathAndQuery, rootPath) {
+		pathAndQuery = rootPath + pathAndQuery
+	}
+	return target, target.parsePathAndQuery(pathAndQuery)
+}
+
+func (t *Target) parsePathAndQuery(raw string) error {
+	rawPath, rawQuery, _ := strings.Cut(raw, queryDelimiter)
+	if !isValidPath(rawPath) {
+		return wrapError(ErrBadRequest, "invalid path")
+	}
+	if !isValidQuery(rawQuery) {
+		return wrapError(ErrBadRequest, "invalid query")
+	}
+	path, err := unescape(rawPath)
+	if err != nil {
+		return err
+	}
+	query, err := parseQuery(rawQuery)
+	if err != nil {
+		return err
+	}
+	t.RawPath = rawPath
+	t.Path = path
+	t.RawQuery = rawQuery
+	t.Query = query
+	return nil
+}
+
+// Segments returns the percent-decoded path segments, splitting on the raw
+// path so that "%2F" stays inside its segment.
+func (t Target) Segments() []string {
+	segments := strings.Split(strings.TrimPrefix(t.RawPath, rootPath), rootPath)
+	for i, segment := range segments {
+		segments[i], _ = unescape(segment)
+	}
+	return segments
+}
+
+func (q Query) Get(key string) string {
+	if values := q[key]; len(values) > 0 {
+		return values[0]
+	}
+	return emptyString
+}
+
+func (q Query) Has(key string) bool {
+	_, ok := q[key]
+	return ok
+}
+
+// parseQuery splits on "&" and "=" and percent-decodes keys and values. A
+// "+" is kept as is: RFC 3986 gives it no special meaning.
+func parseQuery(rawQuery string) (Query, error) {
+	query := Query{}
+	for _, pair := range strings.Split(rawQuery, queryPairDelimiter) {
+		if pair == emptyString {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, queryValueDelimiter)
+		key, err := unescape(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescape(rawValue)
+		if err != nil {
+			return nil, err
+		}
+		query[key] = append(query[key], value)
+	}
+	return query, nil
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+percentEncodingWidth > len(s) || !isHexDigit(s[i+1]) || !isHexDigit(s[i+2]) {
+			return emptyString, wrapError(ErrBadRequest, "invalid percent-encoding")
+		}
+		b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+		i += percentEncodingWidth - 1
+	}
+	return b.String(), nil
+}
+
+// validateAuthority checks host [ ":" port ]. Userinfo is rejected as RFC
+// 9110 section 4.2.4 asks of recipients. CONNECT requires a port.
+func validateAuthority(authority string, requirePort bool) error {
+	if authority == emptyString || strings.Contains(authority, userinfoDelimiter) {
+		return wrapError(ErrBadRequest, authority)
+	}
+	host, port, hasPort := SplitHostPort(authority)
+	if host == emptyString || !isValidHost(host) {
+		return wrapError(ErrBadRequest, authority)
+	}
+	if requirePort && port == emptyString {
+		return wrapError(ErrBadRequest, "authority-form requires a port")
+	}
+	if hasPort && !isValidPort(port) {
+		return wrapError(ErrBadRequest, authority)
+	}
+	return nil
+}
+
+// SplitHostPort splits an authority into host and port, keeping IPv6
+// literals in their brackets. The host is empty if the authority is malformed.
+func SplitHostPort(authority string) (string, string, bool) {
+	if strings.HasPrefix(authority, "[") {
+		end := strings.Index(authority, "]")
+		if end == -1 {
+			return emptyString, emptyString, false
+		}
+		host, rest := authority[:end+1], authority[end+1:]
+		if rest == emptyString {
+			return host, emptyString, false
+		}
+		if !strings.HasPrefix(rest, portDelimiter) {
+			return emptyString, emptyString, false
+		}
+		return host, rest[1:], true
+	}
+	host, port, hasPort := strings.Cut(authority, portDelimiter)
+	if strings.Contains(port, portDelimiter) {
+		return emptyString, emptyString, false
+	}
+	return host, port, hasPort
+}
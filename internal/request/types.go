@@ -1,7 +1,11 @@
 package request
 
 import (
+	"context"
+	"crypto/tls"
 	"httpfromtcp/internal/headers"
+	"io"
+	"sync"
 )
 
 type ParseState int
@@ -10,20 +14,54 @@ const (
 	PendingState ParseState = iota
 	ParsingHeadersState
 	ParsingBodyState
+	ParsingChunkSizeState
+	ParsingChunkDataState
+	ParsingChunkDataEndState
+	ParsingTrailersState
 	DoneState
 )
 
 type Request struct {
 	RequestLine RequestLine
+	Target      Target
+	Host        string
 	State       ParseState
-	Headers     headers.Headers
-	Body        []byte
+	Headers     *headers.Headers
+	Body        io.ReadCloser
+	Trailers    *headers.Headers
+	Params      map[string]string
+
+	// TLS is the connection state for requests received over TLS, nil
+	// otherwise.
+	TLS *tls.ConnectionState
+
+	ctx           context.Context
+	limits        Limits
+	body          *body
+	bodyRead      int64
+	bodyRemaining int
+	bodyBuffer    []byte
+	bodyErr       error
+
+	scanned    int
+	emptyLines int
+	fieldBytes int
+	fieldCount int
+
+	lenient    bool
+	closeAfter bool
+
+	continueMu     sync.Mutex
+	expectContinue bool
+	onContinue     func() error
 }
 
-func newRequest() Request {
+func newRequest(limits Limits) Request {
 	return Request{
-		State:   PendingState,
-		Headers: headers.NewHeaders(),
+		limits:   limits,
+		State:    PendingState,
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
 	}
 }
 
@@ -32,3 +70,44 @@ type RequestLine struct {
 	RequestTarget string
 	Method        string
 }
+
+type TargetForm int
+
+const (
+	OriginForm TargetForm = iota
+	AbsoluteForm
+	AuthorityForm
+	AsteriskForm
+)
+
+// Target is the parsed request-target. RawPath keeps percent-encodings as
+// sent, so an encoded "/" stays distinguishable from a segment separator.
+type Target struct {
+	Form      TargetForm
+	Scheme    string
+	Authority string
+	RawPath   string
+	Path      string
+	RawQuery  string
+	Query     Query
+}
+
+type Query map[string][]string
+
+// Reader reads requests from a connection. Bytes between start and end of
+// buf have been read but not yet consumed by the parser.
+type Reader struct {
+	reader  io.Reader
+	buf     []byte
+	start   int
+	end     int
+	current *Request
+	limits  Limits
+	lenient bool
+}
+
+type body struct {
+	reader *Reader
+	req    *Request
+	closed bool
+}
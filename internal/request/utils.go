@@ -1,17 +1,278 @@
 package request
 
 import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"strconv"
 	"strings"
 )
 
-func validateRequestLineFormat(requestLine string) error {
-	parts := strings.Split(requestLine, spaceDelimiter)
-	if len(parts) != expectedRequestLineParts {
-		return ErrBadRequest
+// validateHttpVersion accepts every HTTP/1.x minor version, as RFC 9110
+// section 2.5 asks of a recipient of the same major version.
+func validateHttpVersion(version string) bool {
+	return version[0] == supportedHttpMajorVersion
+}
+
+func isWellFormedHttpVersion(version string) bool {
+	if len(version) != httpVersionLength || version[1] != '.' {
+		return false
+	}
+	return isDigit(version[0]) && isDigit(version[2])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseDigits parses a Content-Length value, which is 1*DIGIT: signs, spaces
+// and other characters that strconv would accept are refused.
+func parseDigits(value string) (int, error) {
+	if value == emptyString || len(value) > maxContentLengthDigits {
+		return 0, wrapError(ErrBadRequest, "invalid Content-Length: "+value)
+	}
+	n := 0
+	for i := 0; i < len(value); i++ {
+		if !isDigit(value[i]) {
+			return 0, wrapError(ErrBadRequest, "invalid Content-Length: "+value)
+		}
+		n = n*10 + int(value[i]-'0')
+	}
+	return n, nil
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func validateTransferEncoding(transferEncoding string) error {
+	codings := strings.Split(transferEncoding, listDelimiter)
+	for i, coding := range codings {
+		coding = strings.TrimSpace(coding)
+		if coding == emptyString {
+			return wrapError(ErrBadRequest, "empty transfer coding")
+		}
+		if !strings.EqualFold(coding, chunkedTransferCoding) {
+			return wrapError(ErrNotImplemented, coding)
+		}
+		if i != len(codings)-1 {
+			return wrapError(ErrBadRequest, "chunked is not the final transfer coding")
+		}
 	}
 	return nil
 }
 
-func validateHttpVersion(version string) bool {
-	return version == supportedHttpVersion
+// parseChunkSize reads the hexadecimal size at the start of a chunk-size
+// line. Chunk extensions are checked and then ignored: strict mode requires
+// the chunk-ext grammar, lenient mode only refuses control characters.
+func parseChunkSize(line []byte, lenient bool) (int, error) {
+	sizeDigits := line
+	if idx := bytes.IndexByte(line, chunkExtensionDelimiter); idx != -1 {
+		sizeDigits = line[:idx]
+		extensions := line[idx:]
+		valid := !hasControl(extensions)
+		if valid && !lenient {
+			valid = validChunkExtensions(extensions)
+		}
+		if !valid {
+			return 0, wrapError(ErrBadRequest, "invalid chunk extension: "+string(line))
+		}
+	}
+	sizeDigits = bytes.TrimRight(sizeDigits, optionalWhitespace)
+	if len(sizeDigits) == 0 || len(sizeDigits) > maxChunkSizeDigits {
+		return 0, wrapError(ErrBadRequest, "invalid chunk size: "+string(line))
+	}
+	size := 0
+	for _, c := range sizeDigits {
+		if !isHexDigit(c) {
+			return 0, wrapError(ErrBadRequest, "invalid chunk size: "+string(line))
+		}
+		size = size*chunkSizeBase + int(unhex(c))
+	}
+	return size, nil
+}
+
+// validChunkExtensions matches RFC 9112 section 7.1.1:
+// *( BWS ";" BWS token [ BWS "=" BWS ( token / quoted-string ) ] ).
+func validChunkExtensions(ext []byte) bool {
+	for i := 0; i < len(ext); {
+		i = skipWhitespace(ext, i)
+		if i == len(ext) || ext[i] != chunkExtensionDelimiter {
+			return false
+		}
+		i = skipWhitespace(ext, i+1)
+		n := tokenLength(ext[i:])
+		if n == 0 {
+			return false
+		}
+		i += n
+		next := skipWhitespace(ext, i)
+		if next == len(ext) || ext[next] != chunkExtensionAssign {
+			i = next
+			continue
+		}
+		i = skipWhitespace(ext, next+1)
+		if i < len(ext) && ext[i] == quoteCharacter {
+			n = quotedStringLength(ext[i:])
+		} else {
+			n = tokenLength(ext[i:])
+		}
+		if n == 0 {
+			return false
+		}
+		i += n
+	}
+	return true
+}
+
+func skipWhitespace(data []byte, i int) int {
+	for i < len(data) && isWhitespace(data[i]) {
+		i++
+	}
+	return i
+}
+
+// tokenLength returns the length of the token at the start of data, 0 if
+// there is none.
+func tokenLength(data []byte) int {
+	end := bytes.IndexAny(data, tokenEndCharacters)
+	if end == -1 {
+		end = len(data)
+	}
+	if !headers.IsToken(string(data[:end])) {
+		return 0
+	}
+	return end
+}
+
+// quotedStringLength returns the length of the quoted-string at the start of
+// data, 0 if it is malformed or unterminated.
+func quotedStringLength(data []byte) int {
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case quoteCharacter:
+			return i + 1
+		case escapeCharacter:
+			i++
+		}
+	}
+	return 0
+}
+
+// hasControl reports a control character other than HTAB.
+func hasControl(data []byte) bool {
+	for _, c := range data {
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isValidPort accepts an empty port, which RFC 3986 allows, or a decimal
+// number up to 65535.
+func isValidPort(port string) bool {
+	for i := 0; i < len(port); i++ {
+		if !isDigit(port[i]) {
+			return false
+		}
+	}
+	if port == emptyString {
+		return true
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n <= maxPort
+}
+
+func isUnreserved(c byte) bool {
+	return isAlpha(c) || isDigit(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isSubDelim(c byte) bool {
+	return strings.IndexByte("!$&'()*+,;=", c) != -1
+}
+
+// isPchar reports whether c may appear unencoded in a path segment; '%' is
+// accepted here and checked when decoding.
+func isPchar(c byte) bool {
+	return isUnreserved(c) || isSubDelim(c) || c == ':' || c == '@' || c == '%'
+}
+
+func isValidPath(path string) bool {
+	for i := 0; i < len(path); i++ {
+		if !isPchar(path[i]) && path[i] != '/' {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidQuery(query string) bool {
+	for i := 0; i < len(query); i++ {
+		if !isPchar(query[i]) && query[i] != '/' && query[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidScheme(scheme string) bool {
+	if scheme == emptyString || !isAlpha(scheme[0]) {
+		return false
+	}
+	for i := 1; i < len(scheme); i++ {
+		c := scheme[i]
+		if !isAlpha(c) && !isDigit(c) && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidHost accepts a bracketed IP literal or a reg-name/IPv4 address.
+func isValidHost(host string) bool {
+	if strings.HasPrefix(host, "[") {
+		if !strings.HasSuffix(host, "]") || len(host) < 3 {
+			return false
+		}
+		for i := 1; i < len(host)-1; i++ {
+			c := host[i]
+			if !isHexDigit(c) && c != ':' && c != '.' {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		if c == '%' {
+			if i+percentEncodingWidth > len(host) || !isHexDigit(host[i+1]) || !isHexDigit(host[i+2]) {
+				return false
+			}
+			i += percentEncodingWidth - 1
+			continue
+		}
+		if !isUnreserved(c) && !isSubDelim(c) {
+			return false
+		}
+	}
+	return true
 }
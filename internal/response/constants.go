@@ -0,0 +1,61 @@
+package response
+
+const (
+	implicitBufferSize = 4 * 1024
+
+	minStatusCode StatusCode = 100
+	maxStatusCode StatusCode = 999
+
+	dateHeader             = "Date"
+	contentLengthHeader    = "Content-Length"
+	transferEncodingHeader = "Transfer-Encoding"
+	chunkedTransferCoding  = "chunked"
+	trailerHeader          = "Trailer"
+	connectionHeader       = "Connection"
+	closeConnectionToken   = "close"
+	keepAliveToken         = "keep-alive"
+	listDelimiter          = ","
+
+	// dateLayout is the IMF-fixdate format of RFC 9110 section 5.6.7
+	dateLayout = "Mon, 02 Jan 2006 15:04:05 GMT"
+)
+
+// prohibitedTrailers are fields that RFC 9110 section 6.5.1 rules out of a
+// trailer section: message framing, routing, request modifiers,
+// authentication, response control data and content processing.
+var prohibitedTrailers = map[string]struct{}{
+	"transfer-encoding":   {},
+	"content-length":      {},
+	"trailer":             {},
+	"connection":          {},
+	"keep-alive":          {},
+	"upgrade":             {},
+	"host":                {},
+	"cache-control":       {},
+	"expect":              {},
+	"max-forwards":        {},
+	"pragma":              {},
+	"range":               {},
+	"te":                  {},
+	"if-match":            {},
+	"if-none-match":       {},
+	"if-modified-since":   {},
+	"if-unmodified-since": {},
+	"if-range":            {},
+	"authorization":       {},
+	"proxy-authorization": {},
+	"www-authenticate":    {},
+	"proxy-authenticate":  {},
+	"cookie":              {},
+	"set-cookie":          {},
+	"age":                 {},
+	"expires":             {},
+	"date":                {},
+	"location":            {},
+	"retry-after":         {},
+	"vary":                {},
+	"warning":             {},
+	"content-encoding":    {},
+	"content-type":        {},
+	"content-range":       {},
+}
@@ -0,0 +1,14 @@
+package response
+
+import "errors"
+
+var (
+	ErrContentLengthExceeded = errors.New("response body exceeds Content-Length")
+	ErrContentLengthShort    = errors.New("response body is shorter than Content-Length")
+	ErrNotChunked            = errors.New("response is not chunked")
+	ErrChunkedBody           = errors.New("chunked response body must be written with WriteChunk")
+	ErrBodyNotAllowed        = errors.New("response status does not allow a body")
+	ErrChunkedBodyDone       = errors.New("chunked response body already terminated")
+	ErrStatusWritten         = errors.New("final status line already written")
+	ErrAborted               = errors.New("response aborted")
+)
@@ -7,17 +7,22 @@ import (
 	"strconv"
 )
 
-func GetDefaultHeaders(contentLen int) headers.Headers {
+func GetDefaultHeaders(contentLen int) *headers.Headers {
 	h := headers.NewHeaders()
-	h["Content-Length"] = strconv.Itoa(contentLen)
-	h["Connection"] = "close"
-	h["Content-Type"] = "text/plain"
+	h.Set("Content-Length", strconv.Itoa(contentLen))
+	h.Set("Content-Type", "text/plain")
 	return h
 }
 
-func WriteHeaders(w io.Writer, headers headers.Headers) error {
-	for k, v := range headers {
-		_, err := fmt.Fprintf(w, "%v: %v\r\n", k, v)
+// WriteHeaders writes the fields and the empty line ending the section. It
+// writes nothing if any field name or value is invalid, so a value carrying
+// CR or LF cannot inject fields or a second response.
+func WriteHeaders(w io.Writer, headers *headers.Headers) error {
+	if err := headers.Validate(); err != nil {
+		return fmt.Errorf("cannot write headers: %w", err)
+	}
+	for _, field := range headers.Fields() {
+		_, err := fmt.Fprintf(w, "%v: %v\r\n", field.Name, field.Value)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,179 @@
+package response
+
+import (
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"strconv"
+	"time"
+)
+
+var now = time.Now
+
+// SetStatus sets the status of an implicit response. It has no effect once
+// the response has been started.
+func (w *Writer) SetStatus(statusCode StatusCode) {
+	if w.state == StateInitial && !w.implicit {
+		w.status = statusCode
+	}
+}
+
+// Write starts an implicit response on first use, freezing the status and the
+// fields set through Header. The body is buffered until it outgrows
+// implicitBufferSize or Flush is called, so short responses are sent with a
+// Content-Length and longer ones are streamed. After an explicit WriteHeaders,
+// Write appends to the body instead.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.state != StateInitial && !w.implicit {
+		return w.writeExplicit(p)
+	}
+	w.begin()
+	if bodyless(w.status) {
+		return 0, fmt.Errorf("cannot write body: status %d does not allow one", w.status)
+	}
+	if w.streaming {
+		return w.writeExplicit(p)
+	}
+	if len(w.pending)+len(p) <= implicitBufferSize {
+		w.pending = append(w.pending, p...)
+		return len(p), nil
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return w.writeExplicit(p)
+}
+
+// Flush sends the head and buffered body of an implicit response now,
+// switching it to chunked encoding unless a Content-Length was set.
+func (w *Writer) Flush() error {
+	if w.state != StateInitial && !w.implicit {
+		return nil
+	}
+	w.begin()
+	if w.streaming {
+		return nil
+	}
+	h := w.committed
+	if !bodyless(w.status) && !h.Has(contentLengthHeader) && !h.Has(transferEncodingHeader) {
+		h.Set(transferEncodingHeader, chunkedTransferCoding)
+	}
+	if err := w.writeHead(h); err != nil {
+		return err
+	}
+	pending := w.pending
+	w.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := w.writeExplicit(pending)
+	return err
+}
+
+// Finish completes the response after the handler returns. A buffered
+// implicit body is sent with a Content-Length, a streamed chunked body gets
+// its last chunk, an open trailer section is closed, and a handler that
+// wrote nothing gets an empty response with the status from SetStatus or 200.
+// An aborted response is left as it is.
+func (w *Writer) Finish() error {
+	if w.aborted {
+		return ErrAborted
+	}
+	if w.trailerOpen {
+		if err := w.closeTrailers(); err != nil {
+			return err
+		}
+	}
+	if w.state != StateInitial && !w.implicit {
+		return w.checkComplete()
+	}
+	w.begin()
+	if !w.streaming && w.committed.Has(transferEncodingHeader) {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if !w.streaming {
+		h := w.committed
+		if !bodyless(w.status) && !h.Has(contentLengthHeader) {
+			h.Set(contentLengthHeader, strconv.Itoa(len(w.pending)))
+		}
+		if err := w.writeHead(h); err != nil {
+			return err
+		}
+		pending := w.pending
+		w.pending = nil
+		if len(pending) > 0 && !bodyless(w.status) {
+			if _, err := w.writeBody(pending); err != nil {
+				return err
+			}
+		}
+	}
+	if w.framing == FramingChunked && !w.chunkedDone {
+		if err := w.WriteChunkedBodyDone(); err != nil {
+			return err
+		}
+	}
+	if w.trailerOpen {
+		if err := w.closeTrailers(); err != nil {
+			return err
+		}
+	}
+	return w.checkComplete()
+}
+
+// Reset drops an implicit response that has not been sent yet so another
+// response can be written in its place. Fields set through Header are kept.
+// It reports false if the head has already gone out.
+func (w *Writer) Reset() bool {
+	if w.state != StateInitial {
+		return false
+	}
+	w.implicit = false
+	w.committed = nil
+	w.pending = nil
+	w.status = 0
+	w.reason = ""
+	return true
+}
+
+func (w *Writer) begin() {
+	if w.implicit {
+		return
+	}
+	w.implicit = true
+	if w.status == 0 {
+		w.status = StatusOK
+	}
+	w.committed = w.Header().Clone()
+}
+
+func (w *Writer) writeHead(h *headers.Headers) error {
+	if !h.Has(dateHeader) {
+		h.Set(dateHeader, now().UTC().Format(dateLayout))
+	}
+	// Check the fields up front so a bad one leaves no status line behind.
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("cannot write headers: %w", err)
+	}
+	if _, _, err := framingOf(w.status, h); err != nil {
+		return err
+	}
+	if err := w.WriteStatusLine(w.status); err != nil {
+		return err
+	}
+	if err := w.writeHeaders(h); err != nil {
+		return err
+	}
+	w.streaming = true
+	return nil
+}
+
+func (w *Writer) writeExplicit(p []byte) (int, error) {
+	if w.state < StateHeadersWritten {
+		return 0, fmt.Errorf("cannot write body: headers not written yet")
+	}
+	if w.framing == FramingChunked {
+		return w.WriteChunk(p)
+	}
+	return w.writeBody(p)
+}
@@ -3,6 +3,8 @@ package response
 import (
 	"httpfromtcp/internal/headers"
 	"io"
+	"slices"
+	"strings"
 )
 
 func WriteSimpleResponse(w io.Writer, statusCode StatusCode, contentType string, body string) error {
@@ -14,7 +16,7 @@ func WriteSimpleResponse(w io.Writer, statusCode StatusCode, contentType string,
 
 	h := GetDefaultHeaders(len(body))
 	if contentType != "" {
-		h["Content-Type"] = contentType
+		h.Set("Content-Type", contentType)
 	}
 	if err := writer.WriteHeaders(h); err != nil {
 		return err
@@ -27,7 +29,7 @@ func WriteSimpleResponse(w io.Writer, statusCode StatusCode, contentType string,
 	return nil
 }
 
-func WriteChunkedResponse(w io.Writer, statusCode StatusCode, contentType string, chunks [][]byte, trailers headers.Headers) error {
+func WriteChunkedResponse(w io.Writer, statusCode StatusCode, contentType string, chunks [][]byte, trailers *headers.Headers) error {
 	writer := NewWriter(w)
 
 	if err := writer.WriteStatusLine(statusCode); err != nil {
@@ -35,11 +37,13 @@ func WriteChunkedResponse(w io.Writer, statusCode StatusCode, contentType string
 	}
 
 	h := headers.NewHeaders()
-	h["Transfer-Encoding"] = "chunked"
+	h.Set("Transfer-Encoding", "chunked")
 	if contentType != "" {
-		h["Content-Type"] = contentType
+		h.Set("Content-Type", contentType)
+	}
+	if names := trailerNames(trailers); len(names) > 0 {
+		h.Set("Trailer", strings.Join(names, ", "))
 	}
-	h["Connection"] = "close"
 	if err := writer.WriteHeaders(h); err != nil {
 		return err
 	}
@@ -54,7 +58,7 @@ func WriteChunkedResponse(w io.Writer, statusCode StatusCode, contentType string
 		return err
 	}
 
-	if len(trailers) > 0 {
+	if trailers.Len() > 0 {
 		if err := writer.WriteTrailers(trailers); err != nil {
 			return err
 		}
@@ -70,3 +74,13 @@ func WriteErrorResponse(w io.Writer, statusCode StatusCode, message string) erro
 func WriteJSONResponse(w io.Writer, statusCode StatusCode, jsonBody string) error {
 	return WriteSimpleResponse(w, statusCode, "application/json", jsonBody)
 }
+
+func trailerNames(trailers *headers.Headers) []string {
+	var names []string
+	for _, field := range trailers.Fields() {
+		if !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, field.Name) }) {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
@@ -3,7 +3,9 @@ package response
 import (
 	"bytes"
 	"httpfromtcp/internal/headers"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,7 +21,9 @@ func TestWriteStatusLine(t *testing.T) {
 		{"Bad Request", StatusBadRequest, "HTTP/1.1 400 Bad Request\r\n"},
 		{"Internal Server Error", StatusInternalServerError, "HTTP/1.1 500 Internal Server Error\r\n"},
 		{"Unknown Status", StatusCode(404), "HTTP/1.1 404 Not Found\r\n"},
-		{"Custom Status", StatusCode(999), "HTTP/1.1 999 Status\r\n"},
+		{"Redirect", StatusMovedPermanently, "HTTP/1.1 301 Moved Permanently\r\n"},
+		{"Unused Status", StatusCode(418), "HTTP/1.1 418 \r\n"},
+		{"Unregistered Status", StatusCode(999), "HTTP/1.1 999 \r\n"},
 	}
 
 	for _, tt := range tests {
@@ -32,18 +36,235 @@ func TestWriteStatusLine(t *testing.T) {
 	}
 }
 
+func TestWriteStatusLine_InvalidCode(t *testing.T) {
+	for _, code := range []StatusCode{0, 99, 1000, -200} {
+		var buf bytes.Buffer
+		assert.Error(t, WriteStatusLine(&buf, code), "code %d", code)
+		assert.Equal(t, "", buf.String())
+	}
+}
+
+func TestWriteStatusLineReason(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteStatusLineReason(&buf, StatusOK, "Fine\tThanks"))
+	assert.Equal(t, "HTTP/1.1 200 Fine\tThanks\r\n", buf.String())
+
+	buf.Reset()
+	assert.Error(t, WriteStatusLineReason(&buf, StatusOK, "OK\r\nX-Injected: 1"))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestStatusText(t *testing.T) {
+	assert.Equal(t, "Early Hints", StatusText(StatusEarlyHints))
+	assert.Equal(t, "Permanent Redirect", StatusText(StatusPermanentRedirect))
+	assert.Equal(t, "Unavailable For Legal Reasons", StatusText(StatusUnavailableForLegalReasons))
+	assert.Equal(t, "Network Authentication Required", StatusText(StatusNetworkAuthenticationRequired))
+	assert.Equal(t, "", StatusText(StatusCode(299)))
+	assert.Equal(t, "404 Not Found", StatusNotFound.String())
+	assert.Equal(t, "299", StatusCode(299).String())
+}
+
+func TestStatusCodeClasses(t *testing.T) {
+	assert.True(t, StatusContinue.IsInformational())
+	assert.True(t, StatusNoContent.IsSuccess())
+	assert.True(t, StatusSeeOther.IsRedirect())
+	assert.True(t, StatusTooManyRequests.IsClientError())
+	assert.True(t, StatusBadGateway.IsServerError())
+	assert.False(t, StatusOK.IsClientError())
+	assert.False(t, StatusCode(600).IsServerError())
+	assert.True(t, StatusCode(600).Valid())
+	assert.False(t, StatusCode(42).Valid())
+}
+
+func TestWriter_SetReason(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetReason("Moved Elsewhere")
+	require.NoError(t, w.WriteStatusLine(StatusMovedPermanently))
+	assert.Equal(t, "HTTP/1.1 301 Moved Elsewhere\r\n", buf.String())
+
+	fixedClock(t)
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.SetStatus(StatusCode(299))
+	w.SetReason("Custom")
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 299 Custom\r\n"))
+}
+
+func TestWriter_WriteInformational(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	h := headers.NewHeaders()
+	h.Add("Link", "</style.css>; rel=preload; as=style")
+	require.NoError(t, w.WriteInformational(StatusContinue, nil))
+	require.NoError(t, w.WriteInformational(StatusEarlyHints, h))
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 100 Continue\r\n\r\n"+
+		"HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload; as=style\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\n"))
+	assert.ErrorIs(t, w.WriteInformational(StatusEarlyHints, h), ErrStatusWritten)
+}
+
+func TestWriter_WriteInformationalInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.Error(t, w.WriteInformational(StatusOK, nil))
+	assert.Error(t, w.WriteInformational(StatusSwitchingProtocols, nil))
+	assert.Equal(t, "", buf.String())
+
+	// An implicit response can still be preceded by interim ones until its
+	// head is sent.
+	_, err := w.Write([]byte("buffered"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteInformational(StatusEarlyHints, nil))
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 103 Early Hints\r\n\r\nHTTP/1.1 200 OK\r\n"))
+}
+
+func TestWriter_HTTP10Chunked(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.UseHTTP10()
+
+	h := chunkedHeaders()
+	h.Set("Trailer", "X-Checksum")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteChunk([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.WriteChunk([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunkedBodyDone())
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc")
+	require.NoError(t, w.WriteTrailers(trailers))
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n\r\nhello world", buf.String())
+	assert.Equal(t, FramingClose, w.Framing())
+	assert.False(t, w.KeepAlive())
+}
+
+func TestWriter_HTTP10KeepAlive(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.UseHTTP10()
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(2)))
+	_, err := w.WriteBody([]byte("ok"))
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "Connection: keep-alive\r\n")
+	assert.True(t, w.KeepAlive())
+}
+
+func TestWriter_HTTP10SkipsInformational(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.UseHTTP10()
+	h := headers.NewHeaders()
+	h.Add("Link", "</app.js>; rel=preload")
+	require.NoError(t, w.WriteInformational(StatusEarlyHints, h))
+	assert.Empty(t, buf.String())
+	assert.Error(t, w.WriteInformational(StatusOK, nil))
+
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+}
+
+func TestWriter_HTTP10ImplicitStreaming(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.UseHTTP10()
+	_, err := w.Write([]byte("part one "))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	_, err = w.Write([]byte("part two"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+testDate+"\r\npart one part two", buf.String())
+	assert.False(t, w.KeepAlive())
+}
+
+func TestWriteHeaders_RejectsInjection(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		err   error
+	}{
+		{"CRLF in value", "X-Echo", "hi\r\nSet-Cookie: evil=1", headers.ErrInvalidFieldValue},
+		{"Bare LF in value", "X-Echo", "hi\nthere", headers.ErrInvalidFieldValue},
+		{"NUL in value", "X-Echo", "hi\x00", headers.ErrInvalidFieldValue},
+		{"Space in name", "X Echo", "hi", headers.ErrInvalidFieldName},
+		{"CRLF in name", "X-Echo\r\n\r\nbody", "hi", headers.ErrInvalidFieldName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := headers.NewHeaders()
+			h.Set("Content-Length", "0")
+			h.Set(tt.key, tt.value)
+			err := WriteHeaders(&buf, h)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, "", buf.String())
+		})
+	}
+}
+
+func TestWriter_TrailerInjection(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	h := chunkedHeaders()
+	h.Set("Trailer", "X-Checksum")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	require.NoError(t, w.WriteChunkedBodyDone())
+	buf.Reset()
+
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc\r\n\r\nHTTP/1.1 200 OK")
+	assert.ErrorIs(t, w.WriteTrailers(trailers), headers.ErrInvalidFieldValue)
+	assert.Equal(t, "", buf.String())
+}
+
+func TestWriter_ImplicitInvalidHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("X-Echo", "user\r\nInjected: 1")
+	_, err := w.Write([]byte("body"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, w.Finish(), headers.ErrInvalidFieldValue)
+	assert.Equal(t, "", buf.String())
+}
+
+func TestWriter_ImplicitInvalidContentLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("Content-Length", "abc")
+	_, err := w.Write([]byte("body"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Finish(), "invalid Content-Length")
+	assert.Equal(t, "", buf.String())
+}
+
 func TestGetDefaultHeaders(t *testing.T) {
 	h := GetDefaultHeaders(42)
-	assert.Equal(t, "42", h["Content-Length"])
-	assert.Equal(t, "close", h["Connection"])
-	assert.Equal(t, "text/plain", h["Content-Type"])
+	assert.Equal(t, "42", h.Get("Content-Length"))
+	assert.False(t, h.Has("Connection"))
+	assert.Equal(t, "text/plain", h.Get("Content-Type"))
 }
 
 func TestWriteHeaders(t *testing.T) {
 	var buf bytes.Buffer
 	h := headers.NewHeaders()
-	h["Content-Type"] = "application/json"
-	h["X-Custom"] = "value"
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Custom", "value")
 
 	err := WriteHeaders(&buf, h)
 	require.NoError(t, err)
@@ -54,6 +275,22 @@ func TestWriteHeaders(t *testing.T) {
 	assert.Contains(t, output, "\r\n\r\n")
 }
 
+func TestWriteHeaders_OrderAndRepeatedFields(t *testing.T) {
+	var buf bytes.Buffer
+	h := headers.NewHeaders()
+	h.Set("Content-Type", "text/html")
+	h.Add("Set-Cookie", "a=1")
+	h.Set("X-Custom", "value")
+	h.Add("Set-Cookie", "b=2; Path=/")
+
+	require.NoError(t, WriteHeaders(&buf, h))
+	assert.Equal(t, "Content-Type: text/html\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"X-Custom: value\r\n"+
+		"Set-Cookie: b=2; Path=/\r\n"+
+		"\r\n", buf.String())
+}
+
 func TestWriteBody(t *testing.T) {
 	var buf bytes.Buffer
 	body := "Hello, World!"
@@ -126,6 +363,15 @@ func TestWriter_WriteChunk(t *testing.T) {
 	var buf bytes.Buffer
 	w := NewWriter(&buf)
 
+	// Should fail before headers are written
+	_, err := w.WriteChunk([]byte("Hello"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "headers not written yet")
+
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders()))
+	buf.Reset()
+
 	// Test empty chunk
 	n, err := w.WriteChunk([]byte{})
 	require.NoError(t, err)
@@ -145,10 +391,105 @@ func TestWriter_WriteChunkedBodyDone(t *testing.T) {
 	var buf bytes.Buffer
 	w := NewWriter(&buf)
 
+	assert.Error(t, w.WriteChunkedBodyDone())
+
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders()))
+	buf.Reset()
+
 	err := w.WriteChunkedBodyDone()
 	require.NoError(t, err)
 	assert.Equal(t, "0\r\n\r\n", buf.String())
 	assert.Equal(t, StateBodyWritten, w.state)
+
+	_, err = w.WriteChunk([]byte("late"))
+	assert.ErrorIs(t, err, ErrChunkedBodyDone)
+	assert.ErrorIs(t, w.WriteChunkedBodyDone(), ErrChunkedBodyDone)
+}
+
+func TestWriter_RepeatedBodyWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(11)))
+	buf.Reset()
+
+	_, err := w.WriteBody([]byte("hello "))
+	require.NoError(t, err)
+	assert.False(t, w.KeepAlive())
+	assert.ErrorIs(t, w.Finish(), ErrContentLengthShort)
+
+	_, err = w.WriteBody([]byte("world"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", buf.String())
+	assert.Equal(t, FramingContentLength, w.Framing())
+	assert.True(t, w.KeepAlive())
+	assert.NoError(t, w.Finish())
+}
+
+func TestWriter_ContentLengthExceeded(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(4)))
+	buf.Reset()
+
+	_, err := w.WriteBody([]byte("abc"))
+	require.NoError(t, err)
+	n, err := w.WriteBody([]byte("de"))
+	assert.ErrorIs(t, err, ErrContentLengthExceeded)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "abc", buf.String())
+	assert.False(t, w.KeepAlive())
+}
+
+func TestWriter_FramingViolations(t *testing.T) {
+	t.Run("chunk on content-length response", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(GetDefaultHeaders(4)))
+		_, err := w.WriteChunk([]byte("data"))
+		assert.ErrorIs(t, err, ErrNotChunked)
+		assert.ErrorIs(t, w.WriteChunkedBodyDone(), ErrNotChunked)
+	})
+
+	t.Run("body on chunked response", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(chunkedHeaders()))
+		_, err := w.WriteBody([]byte("data"))
+		assert.ErrorIs(t, err, ErrChunkedBody)
+	})
+
+	t.Run("body on bodyless status", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusNoContent))
+		require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+		_, err := w.WriteBody([]byte("data"))
+		assert.ErrorIs(t, err, ErrBodyNotAllowed)
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("invalid content-length", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		buf.Reset()
+		h := headers.NewHeaders()
+		h.Set("Content-Length", "-1")
+		assert.Error(t, w.WriteHeaders(h))
+		assert.Equal(t, "", buf.String())
+	})
+
+	t.Run("close-delimited", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+		_, err := w.WriteBody([]byte("data"))
+		require.NoError(t, err)
+		assert.Equal(t, FramingClose, w.Framing())
+		assert.False(t, w.KeepAlive())
+	})
 }
 
 func TestWriter_WriteTrailers(t *testing.T) {
@@ -157,19 +498,84 @@ func TestWriter_WriteTrailers(t *testing.T) {
 
 	// Should fail before body is written
 	h := headers.NewHeaders()
-	h["X-Trailer"] = "value"
+	h.Set("X-Trailer", "value")
 	err := w.WriteTrailers(h)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "body not written yet")
 
-	// Should succeed after body is written
+	// Should fail on a response that is not chunked
 	require.NoError(t, w.WriteStatusLine(StatusOK))
 	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
 	_, err = w.WriteBody([]byte{})
 	require.NoError(t, err)
+	assert.Error(t, w.WriteTrailers(h))
+
+	// Should succeed after the last chunk of a chunked response declaring the field
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	declared := headers.NewHeaders()
+	declared.Set("Transfer-Encoding", "chunked")
+	declared.Set("Trailer", "X-Trailer")
+	require.NoError(t, w.WriteHeaders(declared))
+	require.NoError(t, w.WriteChunkedBodyDone())
+	assert.False(t, w.KeepAlive())
 	err = w.WriteTrailers(h)
 	require.NoError(t, err)
-	assert.Contains(t, buf.String(), "X-Trailer: value\r\n\r\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n0\r\nX-Trailer: value\r\n\r\n"), buf.String())
+	assert.True(t, w.KeepAlive())
+}
+
+func TestWriter_TrailerValidation(t *testing.T) {
+	start := func(declared string) (*Writer, *bytes.Buffer) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		h := headers.NewHeaders()
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", declared)
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteChunk([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, w.WriteChunkedBodyDone())
+		return w, &buf
+	}
+
+	tests := []struct {
+		name     string
+		declared string
+		field    string
+	}{
+		{"undeclared field", "X-Checksum", "X-Other"},
+		{"framing field", "Content-Length", "Content-Length"},
+		{"routing field", "Host", "Host"},
+		{"auth field", "Authorization", "Authorization"},
+		{"cookie", "Set-Cookie", "Set-Cookie"},
+		{"content processing field", "Content-Type", "content-type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, buf := start(tt.declared)
+			before := buf.String()
+			trailers := headers.NewHeaders()
+			trailers.Set(tt.field, "value")
+			assert.Error(t, w.WriteTrailers(trailers))
+			assert.Equal(t, before, buf.String())
+
+			require.NoError(t, w.Finish())
+			assert.True(t, strings.HasSuffix(buf.String(), "0\r\n\r\n"), buf.String())
+			assert.True(t, w.KeepAlive())
+		})
+	}
+
+	t.Run("declared fields are case-insensitive", func(t *testing.T) {
+		w, buf := start("x-checksum, X-Length")
+		trailers := headers.NewHeaders()
+		trailers.Set("X-Checksum", "abc")
+		trailers.Set("x-length", "4")
+		require.NoError(t, w.WriteTrailers(trailers))
+		assert.True(t, strings.HasSuffix(buf.String(), "4\r\ndata\r\n0\r\nX-Checksum: abc\r\nx-length: 4\r\n\r\n"), buf.String())
+	})
 }
 
 func TestWriter_StateTransitions(t *testing.T) {
@@ -200,7 +606,8 @@ func TestWriter_WriteChunkedResponse(t *testing.T) {
 	// Write status and headers
 	require.NoError(t, w.WriteStatusLine(StatusOK))
 	h := headers.NewHeaders()
-	h["Transfer-Encoding"] = "chunked"
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "X-Checksum")
 	require.NoError(t, w.WriteHeaders(h))
 
 	// Write chunks
@@ -216,11 +623,11 @@ func TestWriter_WriteChunkedResponse(t *testing.T) {
 
 	// Write trailers
 	trailers := headers.NewHeaders()
-	trailers["X-Checksum"] = "abc123"
+	trailers.Set("X-Checksum", "abc123")
 	require.NoError(t, w.WriteTrailers(trailers))
 
-	expected := "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" +
-		"5\r\nHello\r\n1\r\n \r\n5\r\nWorld\r\n0\r\n\r\nX-Checksum: abc123\r\n\r\n"
+	expected := "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Checksum\r\n\r\n" +
+		"5\r\nHello\r\n1\r\n \r\n5\r\nWorld\r\n0\r\nX-Checksum: abc123\r\n\r\n"
 	assert.Equal(t, expected, buf.String())
 }
 
@@ -237,11 +644,68 @@ func TestWriter_WriteFullResponse(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Content-Length: 13")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "Content-Type: text/plain")
 	assert.Contains(t, output, "\r\n\r\nHello, World!")
 }
 
+func TestWriter_KeepAlive(t *testing.T) {
+	t.Run("Content-Length response", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(GetDefaultHeaders(2)))
+		_, err := w.WriteBody([]byte("ok"))
+		require.NoError(t, err)
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("Nothing written", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("Unframed body", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("Unterminated chunked body", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		h := headers.NewHeaders()
+		h.Set("Transfer-Encoding", "chunked")
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteChunk([]byte("data"))
+		require.NoError(t, err)
+		assert.False(t, w.KeepAlive())
+		require.NoError(t, w.WriteChunkedBodyDone())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("Handler sends Connection: close", func(t *testing.T) {
+		w := NewWriter(&bytes.Buffer{})
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		h := GetDefaultHeaders(0)
+		h.Set("Connection", "close")
+		require.NoError(t, w.WriteHeaders(h))
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("Keep-alive disabled adds Connection: close", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.SetKeepAlive(false)
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		h := GetDefaultHeaders(0)
+		require.NoError(t, w.WriteHeaders(h))
+		assert.False(t, w.KeepAlive())
+		assert.Contains(t, buf.String(), "Connection: close\r\n")
+		assert.False(t, h.Has("Connection"))
+	})
+}
+
 func TestWriteSimpleResponse(t *testing.T) {
 	var buf bytes.Buffer
 
@@ -251,7 +715,7 @@ func TestWriteSimpleResponse(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Content-Length: 14")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "Content-Type: text/html")
 	assert.Contains(t, output, "\r\n\r\n<h1>Hello</h1>")
 }
@@ -265,7 +729,7 @@ func TestWriteSimpleResponse_DefaultContentType(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Content-Length: 11")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "Content-Type: text/plain")
 	assert.Contains(t, output, "\r\n\r\nHello World")
 }
@@ -280,7 +744,7 @@ func TestWriteChunkedResponse(t *testing.T) {
 	}
 
 	trailers := headers.NewHeaders()
-	trailers["X-Checksum"] = "abc123"
+	trailers.Set("X-Checksum", "abc123")
 
 	err := WriteChunkedResponse(&buf, StatusOK, "text/plain", chunks, trailers)
 	require.NoError(t, err)
@@ -289,8 +753,9 @@ func TestWriteChunkedResponse(t *testing.T) {
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Transfer-Encoding: chunked")
 	assert.Contains(t, output, "Content-Type: text/plain")
-	assert.Contains(t, output, "Connection: close")
-	assert.Contains(t, output, "\r\n\r\n5\r\nHello\r\n1\r\n \r\n5\r\nWorld\r\n0\r\n\r\nX-Checksum: abc123\r\n\r\n")
+	assert.NotContains(t, output, "Connection: close")
+	assert.Contains(t, output, "Trailer: X-Checksum\r\n")
+	assert.True(t, strings.HasSuffix(output, "\r\n\r\n5\r\nHello\r\n1\r\n \r\n5\r\nWorld\r\n0\r\nX-Checksum: abc123\r\n\r\n"), output)
 }
 
 func TestWriteChunkedResponse_NoTrailers(t *testing.T) {
@@ -306,7 +771,7 @@ func TestWriteChunkedResponse_NoTrailers(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Transfer-Encoding: chunked")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "\r\n\r\n4\r\nTest\r\n0\r\n\r\n")
 }
 
@@ -319,7 +784,7 @@ func TestWriteErrorResponse(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 400 Bad Request")
 	assert.Contains(t, output, "Content-Length: 15")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "Content-Type: text/plain")
 	assert.Contains(t, output, "\r\n\r\nInvalid request")
 }
@@ -334,7 +799,217 @@ func TestWriteJSONResponse(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "HTTP/1.1 200 OK")
 	assert.Contains(t, output, "Content-Length: 35")
-	assert.Contains(t, output, "Connection: close")
+	assert.NotContains(t, output, "Connection: close")
 	assert.Contains(t, output, "Content-Type: application/json")
 	assert.Contains(t, output, "\r\n\r\n"+jsonData)
 }
+
+func TestWriter_OmitBody(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.OmitBody()
+
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	n, err := w.WriteChunk([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	require.NoError(t, w.WriteChunkedBodyDone())
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n", buf.String())
+	assert.True(t, w.KeepAlive())
+}
+
+func TestWriter_PendingHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("X-Request-ID", "abc")
+	w.Header().Set("Content-Type", "application/json")
+
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nContent-Type: text/plain\r\nX-Request-ID: abc\r\n\r\n", buf.String())
+}
+
+func TestWriter_BufferedCommit(t *testing.T) {
+	var buf bytes.Buffer
+	parent := NewWriter(&buf)
+	parent.Header().Set("X-Outer", "1")
+	child := NewBufferedWriter(parent)
+
+	require.NoError(t, child.WriteStatusLine(StatusOK))
+	require.NoError(t, child.WriteHeaders(GetDefaultHeaders(2)))
+	_, err := child.WriteBody([]byte("hi"))
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+	assert.False(t, parent.Written())
+
+	require.NoError(t, child.Commit())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nContent-Type: text/plain\r\nX-Outer: 1\r\n\r\nhi", buf.String())
+	assert.True(t, parent.Written())
+	assert.True(t, parent.KeepAlive())
+	assert.Equal(t, StatusOK, parent.Status())
+}
+
+func TestWriter_StatusBeforeFinish(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{})
+	assert.Equal(t, StatusOK, w.Status())
+	w.SetStatus(StatusNoContent)
+	assert.Equal(t, StatusNoContent, w.Status())
+
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusNotFound))
+	assert.Equal(t, StatusNotFound, w.Status())
+}
+
+func fixedClock(t *testing.T) {
+	t.Helper()
+	now = func() time.Time { return time.Date(2024, time.March, 5, 14, 7, 9, 0, time.FixedZone("CET", 3600)) }
+	t.Cleanup(func() { now = time.Now })
+}
+
+const testDate = "Date: Tue, 05 Mar 2024 13:07:09 GMT\r\n"
+
+func TestWriter_ImplicitContentLength(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("Content-Type", "text/plain")
+
+	_, err := w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	w.Header().Set("X-Too-Late", "ignored")
+	w.SetStatus(StatusNotFound)
+	assert.True(t, w.Written())
+	assert.Empty(t, buf.String())
+
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 11\r\n"+testDate+"\r\nhello world", buf.String())
+	assert.True(t, w.KeepAlive())
+}
+
+func TestWriter_ImplicitStatus(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetStatus(StatusNotFound)
+	_, err := w.Write([]byte("nope"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 404 Not Found\r\nContent-Length: 4\r\n"), buf.String())
+}
+
+func TestWriter_ImplicitEmptyResponse(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n"+testDate+"\r\n", buf.String())
+
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.SetStatus(StatusNoContent)
+	_, err := w.Write([]byte("body"))
+	assert.Error(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\n"+testDate+"\r\n", buf.String())
+}
+
+func TestWriter_ImplicitStreaming(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	_, err := w.Write([]byte("first"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n"+testDate+"\r\n5\r\nfirst\r\n", buf.String())
+
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+	assert.False(t, w.KeepAlive())
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(buf.String(), "5\r\nfirst\r\n6\r\nsecond\r\n0\r\n\r\n"), buf.String())
+	assert.True(t, w.KeepAlive())
+}
+
+func TestWriter_ImplicitLargeBodyStreams(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	body := strings.Repeat("x", implicitBufferSize+1)
+
+	_, err := w.Write([]byte(body))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(buf.String(), body+"\r\n0\r\n\r\n"))
+}
+
+func TestWriter_ImplicitDeclaredContentLength(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("Content-Length", "3")
+
+	_, err := w.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 3\r\n"+testDate+"\r\nabc", buf.String())
+}
+
+func TestWriter_ImplicitHead(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.OmitBody()
+
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n"+testDate+"\r\n", buf.String())
+}
+
+func TestWriter_Reset(t *testing.T) {
+	fixedClock(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("X-Request-ID", "abc")
+	_, err := w.Write([]byte("partial"))
+	require.NoError(t, err)
+
+	assert.True(t, w.Reset())
+	assert.False(t, w.Written())
+	require.NoError(t, w.WriteStatusLine(StatusInternalServerError))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	assert.NotContains(t, buf.String(), "partial")
+	assert.Contains(t, buf.String(), "X-Request-ID: abc\r\n")
+	assert.False(t, w.Reset())
+}
+
+func TestWriter_WriteAfterExplicitHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	_, err := w.Write([]byte("early"))
+	assert.Error(t, err)
+
+	h := headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n", buf.String())
+}
+
+func chunkedHeaders() *headers.Headers {
+	h := headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	return h
+}
@@ -1,6 +1,11 @@
 package response
 
-import "io"
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"io"
+	"sync/atomic"
+)
 
 type WriterState int
 
@@ -12,14 +17,40 @@ const (
 )
 
 type Writer struct {
-	w     io.Writer
-	state WriterState
+	w      io.Writer
+	state  WriterState
+	status StatusCode
+	reason string
+
+	contentLength int64
+	bodyWritten   int64
+	keepAlive     atomic.Bool
+	framing       Framing
+	chunkedDone   bool
+	unchunked     bool
+	trailers      []string
+	trailerOpen   bool
+	omitBody      bool
+	http10        bool
+	aborted       bool
+	header        *headers.Headers
+
+	implicit  bool
+	streaming bool
+	committed *headers.Headers
+	pending   []byte
+
+	parent *Writer
+	buf    *bytes.Buffer
 }
 
-type StatusCode int
+// Framing is how the end of a response body is signalled, as chosen from the
+// status and header fields when the head is written.
+type Framing int
 
 const (
-	StatusOK                  StatusCode = 200
-	StatusBadRequest          StatusCode = 400
-	StatusInternalServerError StatusCode = 500
+	FramingClose Framing = iota
+	FramingNone
+	FramingContentLength
+	FramingChunked
 )
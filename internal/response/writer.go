@@ -1,53 +1,258 @@
 package response
 
 import (
+	"bytes"
 	"fmt"
 	"httpfromtcp/internal/headers"
 	"io"
+	"strconv"
+	"strings"
 )
 
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{w: w, state: StateInitial}
+	writer := &Writer{w: w, state: StateInitial}
+	writer.keepAlive.Store(true)
+	return writer
+}
+
+// NewBufferedWriter returns a writer that collects the response in memory
+// until Commit copies it into parent.
+func NewBufferedWriter(parent *Writer) *Writer {
+	buf := &bytes.Buffer{}
+	writer := &Writer{
+		w:        buf,
+		state:    StateInitial,
+		omitBody: parent.omitBody,
+		http10:   parent.http10,
+		header:   parent.Header().Clone(),
+		parent:   parent,
+		buf:      buf,
+	}
+	writer.keepAlive.Store(parent.keepAlive.Load())
+	return writer
+}
+
+func (w *Writer) Commit() error {
+	if w.parent == nil || w.state == StateInitial {
+		return nil
+	}
+	p := w.parent
+	if p.state != StateInitial || p.implicit {
+		return fmt.Errorf("cannot commit: parent response already written")
+	}
+	if _, err := p.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	p.state = w.state
+	p.status = w.status
+	p.framing = w.framing
+	p.contentLength = w.contentLength
+	p.bodyWritten = w.bodyWritten
+	p.chunkedDone = w.chunkedDone
+	p.unchunked = w.unchunked
+	p.trailers = w.trailers
+	p.trailerOpen = w.trailerOpen
+	p.keepAlive.Store(p.keepAlive.Load() && w.keepAlive.Load())
+	w.buf.Reset()
+	return nil
+}
+
+func (w *Writer) Header() *headers.Headers {
+	if w.header == nil {
+		w.header = headers.NewHeaders()
+	}
+	return w.header
+}
+
+// Status reports the status of the response: the one written, or else the
+// one Finish will send, from SetStatus or the implicit 200.
+func (w *Writer) Status() StatusCode {
+	if w.status == 0 && w.state == StateInitial {
+		return StatusOK
+	}
+	return w.status
+}
+
+// SetReason overrides the standard reason phrase of the next status line,
+// explicit or implicit. It has no effect once the status line is out.
+func (w *Writer) SetReason(reason string) {
+	if w.state == StateInitial {
+		w.reason = reason
+	}
+}
+
+func (w *Writer) SetKeepAlive(keepAlive bool) {
+	w.keepAlive.Store(keepAlive)
+}
+
+func (w *Writer) OmitBody() {
+	w.omitBody = true
+}
+
+// UseHTTP10 adapts the response to an HTTP/1.0 client, which cannot decode
+// chunked coding: chunked responses are sent close-delimited instead, and a
+// persistent connection is announced with "Connection: keep-alive".
+func (w *Writer) UseHTTP10() {
+	w.http10 = true
+}
+
+// Abort gives up on a response that is partly sent: Finish then writes
+// nothing more, so a truncated body is not framed as a complete one, and the
+// connection is closed.
+func (w *Writer) Abort() {
+	w.aborted = true
+	w.SetKeepAlive(false)
+}
+
+func (w *Writer) Written() bool {
+	return w.state != StateInitial || w.implicit
+}
+
+func (w *Writer) Framing() Framing {
+	return w.framing
+}
+
+// KeepAlive reports whether the connection can carry another response: the
+// head must be out and the body complete according to its framing.
+func (w *Writer) KeepAlive() bool {
+	if !w.keepAlive.Load() || w.state < StateHeadersWritten {
+		return false
+	}
+	if w.omitBody {
+		return true
+	}
+	switch w.framing {
+	case FramingNone:
+		return true
+	case FramingContentLength:
+		return w.bodyWritten == w.contentLength
+	case FramingChunked:
+		return w.chunkedDone
+	default:
+		return false
+	}
 }
 
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
 	if w.state != StateInitial {
 		return fmt.Errorf("cannot write status line: already written or out of order")
 	}
-	if err := WriteStatusLine(w.w, statusCode); err != nil {
+	reason := w.reason
+	if reason == "" {
+		reason = StatusText(statusCode)
+	}
+	if err := WriteStatusLineReason(w.w, statusCode, reason); err != nil {
 		return err
 	}
+	w.status = statusCode
 	w.state = StateStatusWritten
 	return nil
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+// WriteInformational sends an interim 1xx response, such as 103 Early Hints,
+// ahead of the final one. It may be called any number of times until the
+// final status line is written. 101 is refused since switching protocols
+// ends the HTTP exchange. HTTP/1.0 clients get nothing: RFC 9110 section 15.2
+// forbids sending them a 1xx response.
+func (w *Writer) WriteInformational(statusCode StatusCode, h *headers.Headers) error {
+	if w.state != StateInitial {
+		return ErrStatusWritten
+	}
+	if !statusCode.IsInformational() || statusCode == StatusSwitchingProtocols {
+		return fmt.Errorf("cannot write interim response: status %d is not a usable 1xx code", int(statusCode))
+	}
+	if w.http10 {
+		return nil
+	}
+	if err := WriteStatusLine(w.w, statusCode); err != nil {
+		return err
+	}
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	return WriteHeaders(w.w, h)
+}
+
+func (w *Writer) WriteHeaders(headers *headers.Headers) error {
 	if w.state != StateStatusWritten {
 		return fmt.Errorf("cannot write headers: status line not written yet")
 	}
+	return w.writeHeaders(w.withPendingHeaders(headers))
+}
+
+func (w *Writer) writeHeaders(headers *headers.Headers) error {
+	framing, contentLength, err := framingOf(w.status, headers)
+	if err != nil {
+		return err
+	}
+	if w.http10 && framing == FramingChunked {
+		headers = withoutChunked(headers)
+		framing = FramingClose
+		w.unchunked = true
+	}
+	if headers.HasToken(connectionHeader, closeConnectionToken) {
+		w.keepAlive.Store(false)
+	} else if !w.keepAlive.Load() {
+		headers = withConnectionClose(headers)
+	} else if w.http10 && (framing != FramingClose || w.omitBody) {
+		headers = withConnectionKeepAlive(headers)
+	}
 	if err := WriteHeaders(w.w, headers); err != nil {
 		return err
 	}
+	w.framing = framing
+	w.contentLength = contentLength
+	w.trailers = declaredTrailers(headers)
 	w.state = StateHeadersWritten
 	return nil
 }
 
+// WriteBody appends to a Content-Length or close-delimited body and may be
+// called repeatedly. Writes past the declared Content-Length are refused
+// without writing anything.
 func (w *Writer) WriteBody(p []byte) (int, error) {
-	if w.state != StateHeadersWritten {
+	if w.state < StateHeadersWritten {
 		return 0, fmt.Errorf("cannot write body: headers not written yet")
 	}
-	n, err := w.w.Write(p)
-	if err != nil {
-		return n, err
+	return w.writeBody(p)
+}
+
+func (w *Writer) writeBody(p []byte) (int, error) {
+	switch w.framing {
+	case FramingChunked:
+		return 0, ErrChunkedBody
+	case FramingNone:
+		if len(p) > 0 {
+			return 0, ErrBodyNotAllowed
+		}
+	case FramingContentLength:
+		if w.bodyWritten+int64(len(p)) > w.contentLength {
+			return 0, ErrContentLengthExceeded
+		}
 	}
 	w.state = StateBodyWritten
-	return n, nil
+	if w.omitBody {
+		w.bodyWritten += int64(len(p))
+		return len(p), nil
+	}
+	n, err := w.w.Write(p)
+	w.bodyWritten += int64(n)
+	return n, err
 }
 
 func (w *Writer) WriteChunk(p []byte) (int, error) {
+	if err := w.checkChunked(); err != nil {
+		return 0, err
+	}
 	if len(p) == 0 {
 		return 0, nil
 	}
+	if w.omitBody {
+		return len(p), nil
+	}
+	if w.unchunked {
+		return w.w.Write(p)
+	}
 
 	_, err := fmt.Fprintf(w.w, "%x\r\n", len(p))
 	if err != nil {
@@ -67,25 +272,178 @@ func (w *Writer) WriteChunk(p []byte) (int, error) {
 	return n, nil
 }
 
+func (w *Writer) checkChunked() error {
+	if w.state < StateHeadersWritten {
+		return fmt.Errorf("cannot write chunk: headers not written yet")
+	}
+	if w.framing != FramingChunked && !w.unchunked {
+		return ErrNotChunked
+	}
+	if w.chunkedDone || w.trailerOpen {
+		return ErrChunkedBodyDone
+	}
+	return nil
+}
+
+// WriteChunkedBodyDone writes the last chunk. When the response declared
+// fields in its Trailer header, the trailer section is left open for
+// WriteTrailers; otherwise the message is terminated right away.
 func (w *Writer) WriteChunkedBodyDone() error {
+	if err := w.checkChunked(); err != nil {
+		return err
+	}
+	if w.omitBody || w.unchunked {
+		w.chunkedDone = true
+		w.state = StateBodyWritten
+		return nil
+	}
+	if len(w.trailers) > 0 {
+		if _, err := w.w.Write([]byte("0\r\n")); err != nil {
+			return err
+		}
+		w.trailerOpen = true
+		w.state = StateBodyWritten
+		return nil
+	}
 	_, err := w.w.Write([]byte("0\r\n\r\n"))
 	if err != nil {
 		return err
 	}
+	w.chunkedDone = true
 	w.state = StateBodyWritten
 	return nil
 }
 
-func (w *Writer) WriteTrailers(h headers.Headers) error {
+// WriteTrailers writes the trailer fields and the final CRLF. Every field
+// must have been announced in the Trailer header and must not be one RFC 9110
+// prohibits in trailers; nothing is written if any field fails.
+func (w *Writer) WriteTrailers(h *headers.Headers) error {
 	if w.state != StateBodyWritten {
 		return fmt.Errorf("cannot write trailers: body not written yet")
 	}
-	for k, v := range h {
-		_, err := fmt.Fprintf(w.w, "%v: %v\r\n", k, v)
+	if w.omitBody || w.unchunked {
+		return nil
+	}
+	if !w.trailerOpen {
+		return fmt.Errorf("cannot write trailers: no open trailer section, declare them in the Trailer header of a chunked response")
+	}
+	for _, field := range h.Fields() {
+		if err := w.checkTrailer(field.Name); err != nil {
+			return err
+		}
+		if err := field.Validate(); err != nil {
+			return fmt.Errorf("cannot write trailers: %w", err)
+		}
+	}
+	for _, field := range h.Fields() {
+		_, err := fmt.Fprintf(w.w, "%v: %v\r\n", field.Name, field.Value)
 		if err != nil {
 			return err
 		}
 	}
-	_, err := w.w.Write([]byte("\r\n"))
-	return err
+	return w.closeTrailers()
+}
+
+func (w *Writer) closeTrailers() error {
+	if _, err := w.w.Write([]byte("\r\n")); err != nil {
+		return err
+	}
+	w.trailerOpen = false
+	w.chunkedDone = true
+	return nil
+}
+
+func (w *Writer) checkTrailer(name string) error {
+	if _, prohibited := prohibitedTrailers[strings.ToLower(name)]; prohibited {
+		return fmt.Errorf("cannot write trailers: %s is not allowed in a trailer section", name)
+	}
+	for _, declared := range w.trailers {
+		if strings.EqualFold(declared, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot write trailers: %s was not declared in the Trailer header", name)
+}
+
+func declaredTrailers(h *headers.Headers) []string {
+	var names []string
+	for _, value := range h.Values(trailerHeader) {
+		for _, name := range strings.Split(value, listDelimiter) {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// withPendingHeaders adds fields set through Header that h does not override.
+func (w *Writer) withPendingHeaders(h *headers.Headers) *headers.Headers {
+	if w.header.Len() == 0 {
+		return h
+	}
+	out := h.Clone()
+	for _, field := range w.header.Fields() {
+		if !h.Has(field.Name) {
+			out.Add(field.Name, field.Value)
+		}
+	}
+	return out
+}
+
+func withConnectionClose(h *headers.Headers) *headers.Headers {
+	out := h.Clone()
+	out.Set(connectionHeader, closeConnectionToken)
+	return out
+}
+
+func withConnectionKeepAlive(h *headers.Headers) *headers.Headers {
+	if h.HasToken(connectionHeader, keepAliveToken) {
+		return h
+	}
+	out := h.Clone()
+	out.Set(connectionHeader, keepAliveToken)
+	return out
+}
+
+// withoutChunked drops the chunked coding and the trailer declaration, which
+// an HTTP/1.0 recipient would not understand.
+func withoutChunked(h *headers.Headers) *headers.Headers {
+	out := h.Clone()
+	out.Del(transferEncodingHeader)
+	out.Del(trailerHeader)
+	return out
+}
+
+// framingOf picks the body framing for a response head, following the order
+// of RFC 9112 section 6.3.
+func framingOf(statusCode StatusCode, h *headers.Headers) (Framing, int64, error) {
+	if bodyless(statusCode) {
+		return FramingNone, 0, nil
+	}
+	if h.HasToken(transferEncodingHeader, chunkedTransferCoding) {
+		return FramingChunked, 0, nil
+	}
+	value := h.Get(contentLengthHeader)
+	if value == "" {
+		return FramingClose, 0, nil
+	}
+	contentLength, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || contentLength < 0 {
+		return FramingClose, 0, fmt.Errorf("cannot write headers: invalid Content-Length %q", value)
+	}
+	return FramingContentLength, contentLength, nil
+}
+
+// checkComplete reports a Content-Length body that ended early. HEAD
+// responses are exempt since their body is never sent.
+func (w *Writer) checkComplete() error {
+	if w.framing == FramingContentLength && !w.omitBody && w.bodyWritten < w.contentLength {
+		return ErrContentLengthShort
+	}
+	return nil
+}
+
+func bodyless(statusCode StatusCode) bool {
+	return statusCode.IsInformational() || statusCode == StatusNoContent || statusCode == StatusNotModified
 }
@@ -0,0 +1,24 @@
+package router
+
+const (
+	MethodGet     = "GET"
+	MethodHead    = "HEAD"
+	MethodPost    = "POST"
+	MethodPut     = "PUT"
+	MethodPatch   = "PATCH"
+	MethodDelete  = "DELETE"
+	MethodOptions = "OPTIONS"
+)
+
+const (
+	pathSeparator  = "/"
+	paramPrefix    = "{"
+	paramSuffix    = "}"
+	wildcardPrefix = "*"
+	currentSegment = "."
+	parentSegment  = ".."
+	allowHeader    = "Allow"
+	allowDelimiter = ", "
+	notFoundBody   = "Not Found"
+	notAllowedBody = "Method Not Allowed"
+)
@@ -0,0 +1,11 @@
+package router
+
+import "httpfromtcp/internal/server"
+
+type Option func(*Router)
+
+func WithNotFoundHandler(h server.Handler) Option {
+	return func(r *Router) {
+		r.notFound = h
+	}
+}
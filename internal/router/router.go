@@ -0,0 +1,126 @@
+package router
+
+import (
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"slices"
+	"strings"
+)
+
+func New(opts ...Option) *Router {
+	r := &Router{root: newNode()}
+	r.routes = routes{router: r}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (rs *routes) Handle(method, pattern string, h server.Handler, middleware ...server.Middleware) {
+	h = server.Chain(h, middleware...)
+	rs.router.root.insert(method, rs.prefix+pattern, server.Chain(h, rs.middleware...))
+}
+
+// Use adds middleware to routes registered afterwards through rs and its
+// groups.
+func (rs *routes) Use(middleware ...server.Middleware) {
+	rs.middleware = append(rs.middleware, middleware...)
+}
+
+func (rs *routes) GET(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodGet, pattern, h, middleware...)
+}
+
+func (rs *routes) HEAD(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodHead, pattern, h, middleware...)
+}
+
+func (rs *routes) POST(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodPost, pattern, h, middleware...)
+}
+
+func (rs *routes) PUT(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodPut, pattern, h, middleware...)
+}
+
+func (rs *routes) PATCH(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodPatch, pattern, h, middleware...)
+}
+
+func (rs *routes) DELETE(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodDelete, pattern, h, middleware...)
+}
+
+func (rs *routes) OPTIONS(pattern string, h server.Handler, middleware ...server.Middleware) {
+	rs.Handle(MethodOptions, pattern, h, middleware...)
+}
+
+func (rs *routes) Group(prefix string) *Group {
+	return &Group{routes: routes{
+		router:     rs.router,
+		prefix:     rs.prefix + strings.TrimSuffix(prefix, pathSeparator),
+		middleware: slices.Clone(rs.middleware),
+	}}
+}
+
+func (r *Router) ServeHTTP(w *response.Writer, req *request.Request) {
+	method := req.RequestLine.Method
+
+	switch req.Target.Form {
+	case request.AuthorityForm:
+		r.writeNotFound(w, req)
+		return
+	case request.AsteriskForm:
+		methods := make(map[string]struct{})
+		r.root.collectMethods(methods)
+		writeOptions(w, allowed(methods))
+		return
+	}
+
+	params := make(map[string]string)
+	n := r.root.match(req.Target.Segments(), params)
+	if n == nil {
+		r.writeNotFound(w, req)
+		return
+	}
+	req.Params = params
+
+	if h := n.handler(method); h != nil {
+		h(w, req)
+		return
+	}
+
+	methods := make(map[string]struct{}, len(n.handlers))
+	for m := range n.handlers {
+		methods[m] = struct{}{}
+	}
+	allow := allowed(methods)
+	if method == MethodOptions {
+		writeOptions(w, allow)
+		return
+	}
+	h := response.GetDefaultHeaders(len(notAllowedBody))
+	h.Set(allowHeader, strings.Join(allow, allowDelimiter))
+	_ = w.WriteStatusLine(response.StatusMethodNotAllowed)
+	_ = w.WriteHeaders(h)
+	_, _ = w.WriteBody([]byte(notAllowedBody))
+}
+
+func (r *Router) writeNotFound(w *response.Writer, req *request.Request) {
+	if r.notFound != nil {
+		r.notFound(w, req)
+		return
+	}
+	_ = w.WriteStatusLine(response.StatusNotFound)
+	_ = w.WriteHeaders(response.GetDefaultHeaders(len(notFoundBody)))
+	_, _ = w.WriteBody([]byte(notFoundBody))
+}
+
+func writeOptions(w *response.Writer, allow []string) {
+	h := headers.NewHeaders()
+	h.Set(allowHeader, strings.Join(allow, allowDelimiter))
+	_ = w.WriteStatusLine(response.StatusNoContent)
+	_ = w.WriteHeaders(h)
+}
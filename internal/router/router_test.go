@@ -0,0 +1,204 @@
+package router
+
+import (
+	"bytes"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, r *Router, method, target string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	if method == MethodHead {
+		w.OmitBody()
+	}
+	r.ServeHTTP(w, req)
+	return buf.String()
+}
+
+func text(body string) func(*response.Writer, *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		_, _ = w.WriteBody([]byte(body))
+	}
+}
+
+func echoParams(names ...string) func(*response.Writer, *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = req.Param(name)
+		}
+		text(strings.Join(values, "|"))(w, req)
+	}
+}
+
+func TestRouterMatching(t *testing.T) {
+	r := New()
+	r.GET("/", text("root"))
+	r.GET("/users", text("list"))
+	r.GET("/users/new", text("new"))
+	r.GET("/users/{id}", echoParams("id"))
+	r.GET("/users/{id}/posts/{post}", echoParams("id", "post"))
+	r.GET("/static/*path", echoParams("path"))
+
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/", "root"},
+		{"/users", "list"},
+		{"/users/new", "new"},
+		{"/users/42", "42"},
+		{"/users/42?verbose=1", "42"},
+		{"/users/42/posts/7", "42|7"},
+		{"/static/css/site.css", "css/site.css"},
+		{"/static/", ""},
+		{"/users/a%2Fb", "a/b"},
+		{"/users/caf%C3%A9/posts/1", "café|1"},
+		{"http://example.com/users/7", "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resp := serve(t, r, MethodGet, tt.target)
+			assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"), resp)
+			assert.True(t, strings.HasSuffix(resp, "\r\n\r\n"+tt.body), resp)
+		})
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+	r := New()
+	r.GET("/users/{id}", echoParams("id"))
+	r.GET("/static/*path", echoParams("path"))
+
+	for _, target := range []string{"/missing", "/users", "/users/", "/users/1/extra", "/static"} {
+		resp := serve(t, r, MethodGet, target)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 404 Not Found\r\n"), target)
+	}
+
+	// Wildcard values never carry dot segments or encoded slashes.
+	for _, target := range []string{
+		"/static/../secret",
+		"/static/css/%2e%2e/%2e%2e/secret",
+		"/static/./site.css",
+		"/static/%2E/site.css",
+		"/static/a%2Fb",
+		"/static/css/..%2F..%2Fsecret",
+	} {
+		resp := serve(t, r, MethodGet, target)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 404 Not Found\r\n"), target)
+	}
+
+	custom := New(WithNotFoundHandler(text("custom")))
+	assert.Contains(t, serve(t, custom, MethodGet, "/nope"), "\r\n\r\ncustom")
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/items", text("get"))
+	r.POST("/items", text("post"))
+
+	resp := serve(t, r, MethodDelete, "/items")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 405 Method Not Allowed\r\n"), resp)
+	assert.Contains(t, resp, "Allow: GET, HEAD, OPTIONS, POST\r\n")
+
+	assert.Contains(t, serve(t, r, MethodPost, "/items"), "\r\n\r\npost")
+}
+
+func TestRouterAutomaticHead(t *testing.T) {
+	r := New()
+	r.GET("/page", text("hello"))
+
+	resp := serve(t, r, MethodHead, "/page")
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\n", resp)
+
+	r.HEAD("/explicit", text("head"))
+	assert.Contains(t, serve(t, r, MethodHead, "/explicit"), "Content-Length: 4\r\n")
+}
+
+func TestRouterAutomaticOptions(t *testing.T) {
+	r := New()
+	r.GET("/a", text("a"))
+	r.PUT("/b", text("b"))
+
+	resp := serve(t, r, MethodOptions, "/a")
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\nAllow: GET, HEAD, OPTIONS\r\n\r\n", resp)
+
+	resp = serve(t, r, MethodOptions, "*")
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\nAllow: GET, HEAD, OPTIONS, PUT\r\n\r\n", resp)
+
+	r.OPTIONS("/b", text("custom"))
+	assert.Contains(t, serve(t, r, MethodOptions, "/b"), "\r\n\r\ncustom")
+}
+
+func TestRouterGroups(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	v1 := api.Group("/v1/")
+	v1.GET("/users/{id}", echoParams("id"))
+	api.DELETE("/cache", text("flushed"))
+
+	assert.Contains(t, serve(t, r, MethodGet, "/api/v1/users/9"), "\r\n\r\n9")
+	assert.Contains(t, serve(t, r, MethodDelete, "/api/cache"), "\r\n\r\nflushed")
+	assert.Contains(t, serve(t, r, MethodGet, "/v1/users/9"), "404 Not Found")
+}
+
+func TestRouterInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r *Router)
+	}{
+		{"missing slash", func(r *Router) { r.GET("users", text("")) }},
+		{"empty param", func(r *Router) { r.GET("/users/{}", text("")) }},
+		{"wildcard not last", func(r *Router) { r.GET("/static/*path/more", text("")) }},
+		{"unnamed wildcard", func(r *Router) { r.GET("/static/*", text("")) }},
+		{"conflicting params", func(r *Router) {
+			r.GET("/users/{id}", text(""))
+			r.GET("/users/{name}/posts", text(""))
+		}},
+		{"duplicate route", func(r *Router) {
+			r.GET("/users", text(""))
+			r.GET("/users", text(""))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Panics(t, func() { tt.register(New()) })
+		})
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	tag := func(value string) server.Middleware {
+		return func(next server.Handler) server.Handler {
+			return func(w *response.Writer, req *request.Request) {
+				w.Header().Add("X-Tag", value)
+				next(w, req)
+			}
+		}
+	}
+
+	r := New()
+	r.Use(tag("global"))
+	r.GET("/plain", text("plain"))
+	r.GET("/route", text("route"), tag("route"))
+	api := r.Group("/api")
+	api.Use(tag("api"))
+	api.GET("/item", text("item"), tag("route"))
+
+	assert.Contains(t, serve(t, r, MethodGet, "/plain"), "X-Tag: global\r\n")
+	assert.Contains(t, serve(t, r, MethodGet, "/route"), "X-Tag: global\r\nX-Tag: route\r\n")
+	assert.Contains(t, serve(t, r, MethodGet, "/api/item"), "X-Tag: global\r\nX-Tag: api\r\nX-Tag: route\r\n")
+	assert.NotContains(t, serve(t, r, MethodGet, "/plain"), "X-Tag: api")
+}
@@ -0,0 +1,147 @@
+package router
+
+import (
+	"fmt"
+	"httpfromtcp/internal/server"
+	"sort"
+	"strings"
+)
+
+func newNode() *node {
+	return &node{static: make(map[string]*node)}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, pathSeparator), pathSeparator)
+}
+
+func (n *node) insert(method, pattern string, h server.Handler) {
+	if !strings.HasPrefix(pattern, pathSeparator) {
+		panic(fmt.Sprintf("router: pattern %q must begin with %q", pattern, pathSeparator))
+	}
+	segments := splitPath(pattern)
+	current := n
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, paramPrefix) && strings.HasSuffix(segment, paramSuffix):
+			name := strings.TrimSuffix(strings.TrimPrefix(segment, paramPrefix), paramSuffix)
+			if name == "" {
+				panic(fmt.Sprintf("router: empty parameter name in pattern %q", pattern))
+			}
+			if current.param == nil {
+				current.param = newNode()
+				current.param.name = name
+			} else if current.param.name != name {
+				panic(fmt.Sprintf("router: parameter %q in pattern %q conflicts with existing parameter %q", name, pattern, current.param.name))
+			}
+			current = current.param
+		case strings.HasPrefix(segment, wildcardPrefix):
+			name := strings.TrimPrefix(segment, wildcardPrefix)
+			if name == "" || i != len(segments)-1 {
+				panic(fmt.Sprintf("router: wildcard in pattern %q must be named and come last", pattern))
+			}
+			if current.wildcard == nil {
+				current.wildcard = newNode()
+				current.wildcard.name = name
+			} else if current.wildcard.name != name {
+				panic(fmt.Sprintf("router: wildcard %q in pattern %q conflicts with existing wildcard %q", name, pattern, current.wildcard.name))
+			}
+			current = current.wildcard
+		default:
+			child, ok := current.static[segment]
+			if !ok {
+				child = newNode()
+				current.static[segment] = child
+			}
+			current = child
+		}
+	}
+	if current.handlers == nil {
+		current.handlers = make(map[string]server.Handler)
+	}
+	if _, exists := current.handlers[method]; exists {
+		panic(fmt.Sprintf("router: duplicate route %s %s", method, pattern))
+	}
+	current.handlers[method] = h
+}
+
+// match prefers static segments over parameters over wildcards, backtracking
+// when a more specific branch fails to match the rest of the path.
+func (n *node) match(segments []string, params map[string]string) *node {
+	if len(segments) == 0 {
+		if n.handlers != nil {
+			return n
+		}
+		return nil
+	}
+	segment, rest := segments[0], segments[1:]
+	if child, ok := n.static[segment]; ok {
+		if found := child.match(rest, params); found != nil {
+			return found
+		}
+	}
+	if n.param != nil && segment != "" {
+		if found := n.param.match(rest, params); found != nil {
+			params[n.param.name] = segment
+			return found
+		}
+	}
+	if n.wildcard != nil && n.wildcard.handlers != nil {
+		if value, ok := wildcardValue(segments); ok {
+			params[n.wildcard.name] = value
+			return n.wildcard
+		}
+	}
+	return nil
+}
+
+// wildcardValue joins the decoded segments a wildcard captures. Segments
+// that are dot segments or hold an encoded "/" are refused: once joined they
+// could not be told apart from real path structure, so a handler using the
+// value as a file path could be led outside its directory.
+func wildcardValue(segments []string) (string, bool) {
+	for _, segment := range segments {
+		if segment == currentSegment || segment == parentSegment || strings.Contains(segment, pathSeparator) {
+			return "", false
+		}
+	}
+	return strings.Join(segments, pathSeparator), true
+}
+
+func (n *node) handler(method string) server.Handler {
+	if h, ok := n.handlers[method]; ok {
+		return h
+	}
+	if method == MethodHead {
+		return n.handlers[MethodGet]
+	}
+	return nil
+}
+
+func (n *node) collectMethods(methods map[string]struct{}) {
+	for method := range n.handlers {
+		methods[method] = struct{}{}
+	}
+	for _, child := range n.static {
+		child.collectMethods(methods)
+	}
+	if n.param != nil {
+		n.param.collectMethods(methods)
+	}
+	if n.wildcard != nil {
+		n.wildcard.collectMethods(methods)
+	}
+}
+
+func allowed(methods map[string]struct{}) []string {
+	if _, ok := methods[MethodGet]; ok {
+		methods[MethodHead] = struct{}{}
+	}
+	methods[MethodOptions] = struct{}{}
+	list := make([]string, 0, len(methods))
+	for method := range methods {
+		list = append(list, method)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,27 @@
+package router
+
+import "httpfromtcp/internal/server"
+
+type Router struct {
+	routes
+	root     *node
+	notFound server.Handler
+}
+
+type Group struct {
+	routes
+}
+
+type routes struct {
+	router     *Router
+	prefix     string
+	middleware []server.Middleware
+}
+
+type node struct {
+	name     string
+	static   map[string]*node
+	param    *node
+	wildcard *node
+	handlers map[string]server.Handler
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+type conn struct {
+	server   *Server
+	netConn  net.Conn
+	buffered *bufio.Reader
+	reader   *request.Reader
+	requests chan pipelinedRequest
+
+	mu          sync.Mutex
+	writer      *response.Writer
+	cond        *sync.Cond
+	inFlight    int
+	closing     bool
+	bodyPending bool
+}
+
+type pipelinedRequest struct {
+	req *request.Request
+	err error
+}
+
+func (s *Server) newConn(netConn net.Conn) *conn {
+	buffered := bufio.NewReader(netConn)
+	reader := request.NewReader(buffered)
+	reader.SetLimits(s.limits)
+	reader.SetLenient(s.lenient)
+	c := &conn{
+		server:   s,
+		netConn:  netConn,
+		buffered: buffered,
+		reader:   reader,
+		requests: make(chan pipelinedRequest, s.getMaxPipelinedRequests()),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *conn) readRequests() {
+	defer close(c.requests)
+	timeout := c.server.getReadTimeout()
+	for {
+		if !c.hasBufferedData() {
+			if !c.waitUntil(func() bool { return c.inFlight == 0 }) {
+				return
+			}
+			if err := c.netConn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error setting read deadline: %v\n", err)
+				return
+			}
+			if _, err := c.buffered.Peek(1); err != nil {
+				return
+			}
+		} else if !c.waitUntil(func() bool { return c.inFlight < cap(c.requests) }) {
+			return
+		}
+		timeout = c.server.getIdleTimeout()
+
+		if !c.begin() {
+			return
+		}
+		if err := c.netConn.SetReadDeadline(time.Now().Add(c.server.getReadTimeout())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting read deadline: %v\n", err)
+			return
+		}
+		req, err := c.reader.ReadRequest()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		hasBody := err == nil && req.State != request.DoneState
+		if hasBody {
+			c.setBodyPending(true)
+		}
+		c.requests <- pipelinedRequest{req: req, err: err}
+		if err != nil || !req.KeepAlive() {
+			return
+		}
+		if hasBody && !c.waitUntil(func() bool { return !c.bodyPending }) {
+			return
+		}
+	}
+}
+
+func (c *conn) serveRequests() {
+	for item := range c.requests {
+		if item.err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing request: %v\n", item.err)
+			c.writeParseError(item.err)
+			return
+		}
+		if err := c.netConn.SetWriteDeadline(time.Now().Add(c.server.getWriteTimeout())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting write deadline: %v\n", err)
+			return
+		}
+
+		w := response.NewWriter(c.netConn)
+		w.SetKeepAlive(item.req.KeepAlive())
+		if item.req.IsHTTP10() {
+			w.UseHTTP10()
+		}
+		if item.req.RequestLine.Method == methodHead {
+			w.OmitBody()
+		}
+		item.req.TLS = c.tlsState()
+		item.req.OnContinue(func() error {
+			return sendContinue(w)
+		})
+		c.setWriter(w)
+		c.server.handler(w, item.req)
+		// A client still waiting for 100 Continue has not sent the body, so
+		// it cannot be discarded and the connection is not reused.
+		continuePending := item.req.ExpectsContinue()
+		if continuePending {
+			w.SetKeepAlive(false)
+		}
+		if !w.Written() && !continuePending && c.answerBodyError(item.req) {
+			return
+		}
+		if err := w.Finish(); err != nil {
+			return
+		}
+		if !w.KeepAlive() || c.server.isShuttingDown() {
+			return
+		}
+		if err := item.req.DiscardBody(maxDiscardBytes); err != nil {
+			return
+		}
+		c.setBodyPending(false)
+		c.finish()
+	}
+}
+
+// sendContinue answers "Expect: 100-continue" unless the final response is
+// already on its way, in which case the client simply sends the body.
+func sendContinue(w *response.Writer) error {
+	err := w.WriteInformational(response.StatusContinue, nil)
+	if errors.Is(err, response.ErrStatusWritten) {
+		return nil
+	}
+	return err
+}
+
+func (c *conn) writeParseError(parseErr error) {
+	if err := c.netConn.SetWriteDeadline(time.Now().Add(c.server.getWriteTimeout())); err != nil {
+		return
+	}
+	body := parseErr.Error()
+	w := response.NewWriter(c.netConn)
+	w.SetKeepAlive(false)
+	_ = w.WriteStatusLine(response.StatusCode(request.StatusCode(parseErr)))
+	_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	_, _ = w.WriteBody([]byte(body))
+}
+
+// answerBodyError reports a request body error, such as a body over the
+// route's limit, in place of a response the handler never started.
+func (c *conn) answerBodyError(req *request.Request) bool {
+	var parseErr *request.ParseError
+	if err := req.DiscardBody(maxDiscardBytes); errors.As(err, &parseErr) {
+		c.writeParseError(err)
+		return true
+	}
+	return false
+}
+
+func (c *conn) hasBufferedData() bool {
+	return c.reader.Buffered() > 0 || c.buffered.Buffered() > 0
+}
+
+func (c *conn) waitUntil(ready func() bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.closing && !ready() {
+		c.cond.Wait()
+	}
+	return !c.closing
+}
+
+func (c *conn) setBodyPending(pending bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodyPending = pending
+	c.cond.Broadcast()
+}
+
+func (c *conn) begin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return false
+	}
+	c.inFlight++
+	return true
+}
+
+func (c *conn) finish() {
+	c.mu.Lock()
+	c.inFlight--
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+func (c *conn) setWriter(w *response.Writer) {
+	if c.server.isShuttingDown() {
+		w.SetKeepAlive(false)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writer = w
+}
+
+func (c *conn) closeIfIdle() {
+	c.mu.Lock()
+	idle := c.inFlight == 0
+	if !idle && c.writer != nil {
+		c.writer.SetKeepAlive(false)
+	}
+	c.mu.Unlock()
+	if idle {
+		c.close()
+	}
+}
+
+func (c *conn) close() {
+	c.mu.Lock()
+	alreadyClosing := c.closing
+	c.closing = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	if alreadyClosing {
+		return
+	}
+	if err := c.netConn.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing connection: %v\n", err)
+	}
+}
+
+// tlsState reports the negotiated TLS parameters. The handshake has already
+// completed by the time a request has been read from the connection.
+func (c *conn) tlsState() *tls.ConnectionState {
+	tlsConn, ok := c.netConn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	return &state
+}
@@ -0,0 +1,18 @@
+package server
+
+import "time"
+
+const (
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 30 * time.Second
+	defaultIdleTimeout  = 120 * time.Second
+
+	defaultMaxPipelinedRequests = 8
+	maxDiscardBytes             = 256 * 1024
+
+	shutdownPollInterval = 10 * time.Millisecond
+
+	methodHead = "HEAD"
+
+	defaultHost = "localhost"
+)
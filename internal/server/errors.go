@@ -0,0 +1,6 @@
+package server
+
+import "errors"
+
+// ErrServerClosed is returned when a listener is added to a closed server.
+var ErrServerClosed = errors.New("server closed")
@@ -2,34 +2,36 @@ package server
 
 import (
 	"fmt"
-	"httpfromtcp/internal/request"
-	"httpfromtcp/internal/response"
 	"net"
 	"os"
-	"time"
 )
 
-func (s *Server) handle(conn net.Conn) {
+func (s *Server) handle(netConn net.Conn) {
+	c := s.newConn(netConn)
+	if !s.trackConn(c) {
+		c.close()
+		return
+	}
+	s.serve(c)
+}
+
+func (s *Server) serve(c *conn) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Fprintf(os.Stderr, "Handler panic recovered: %v\n", r)
 		}
-		if err := conn.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error closing connection: %v\n", err)
-		}
+		c.close()
+		s.untrackConn(c)
 	}()
 
-	if err := conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting connection deadline: %v\n", err)
-		return
-	}
+	go c.readRequests()
+	c.serveRequests()
+}
 
-	r, err := request.RequestFromReader(conn)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing request: %v\n", err)
-		return
+// Chain wraps h so that the first middleware is the outermost.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
 	}
-
-	w := response.NewWriter(conn)
-	s.handler(w, r)
+	return h
 }
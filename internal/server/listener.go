@@ -2,18 +2,24 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"os"
 )
 
-func (s *Server) listen() {
+func (s *Server) listen(l net.Listener) {
 	for {
-		conn, err := s.Listener.Accept()
+		netConn, err := l.Accept()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error accepting connection: %v\n", err)
+			if !s.isShuttingDown() {
+				fmt.Fprintf(os.Stderr, "Error accepting connection: %v\n", err)
+			}
 			return
 		}
-		go func() {
-			s.handle(conn)
-		}()
+		c := s.newConn(netConn)
+		if !s.trackConn(c) {
+			c.close()
+			continue
+		}
+		go s.serve(c)
 	}
 }
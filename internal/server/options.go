@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/tls"
+	"httpfromtcp/internal/request"
+	"time"
+)
+
+type Option func(*Server)
+
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = d
+	}
+}
+
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = d
+	}
+}
+
+func WithMaxPipelinedRequests(n int) Option {
+	return func(s *Server) {
+		s.maxPipelinedRequests = n
+	}
+}
+
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+func WithLimits(limits request.Limits) Option {
+	return func(s *Server) {
+		s.limits = limits
+	}
+}
+
+// WithTLS serves HTTPS: connections are wrapped in crypto/tls with config,
+// typically one from certs.Store.TLSConfig so certificates follow SNI and
+// reloads.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = config
+	}
+}
+
+// WithLenientParsing relaxes strict RFC 9112 request parsing for legacy
+// clients; see request.Reader.SetLenient.
+func WithLenientParsing() Option {
+	return func(s *Server) {
+		s.lenient = true
+	}
+}
+
+func (s *Server) getIdleTimeout() time.Duration {
+	if s.idleTimeout > 0 {
+		return s.idleTimeout
+	}
+	return defaultIdleTimeout
+}
+
+func (s *Server) getReadTimeout() time.Duration {
+	if s.readTimeout > 0 {
+		return s.readTimeout
+	}
+	return defaultReadTimeout
+}
+
+func (s *Server) getWriteTimeout() time.Duration {
+	if s.writeTimeout > 0 {
+		return s.writeTimeout
+	}
+	return defaultWriteTimeout
+}
+
+func (s *Server) getMaxPipelinedRequests() int {
+	if s.maxPipelinedRequests > 0 {
+		return s.maxPipelinedRequests
+	}
+	return defaultMaxPipelinedRequests
+}
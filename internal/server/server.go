@@ -1,30 +1,182 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 )
 
-func Serve(port int, h Handler) (*Server, error) {
-	portString := strconv.Itoa(port)
-	tcpListener, err := net.Listen("tcp", "localhost:"+portString)
+// Serve listens on localhost:port. Port 0 picks a free port, which is then
+// reported in Server.Port.
+func Serve(port int, h Handler, opts ...Option) (*Server, error) {
+	return ServeAddr("tcp", net.JoinHostPort(defaultHost, strconv.Itoa(port)), h, opts...)
+}
+
+// ServeAddr listens on any address net.Listen accepts, such as
+// ("tcp", "0.0.0.0:8080"), ("tcp6", "[::1]:0") or ("unix", "/run/app.sock").
+func ServeAddr(network, address string, h Handler, opts ...Option) (*Server, error) {
+	l, err := net.Listen(network, address)
 	if err != nil {
 		return nil, err
 	}
+	return ServeListener(l, h, opts...)
+}
+
+// ServeListener serves connections accepted from l, which the server closes
+// along with itself.
+func ServeListener(l net.Listener, h Handler, opts ...Option) (*Server, error) {
 	s := Server{
-		State:    OpenState,
-		Port:     port,
-		Listener: tcpListener,
-		handler:  h,
+		State:   OpenState,
+		handler: h,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	s.handler = Chain(h, s.middleware...)
+	if err := s.AddListener(l); err != nil {
+		return nil, err
 	}
-	go s.listen()
 	return &s, nil
 }
 
+// Listen adds a listener on network and address and returns the bound
+// address.
+func (s *Server) Listen(network, address string) (net.Addr, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.AddListener(l); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return l.Addr(), nil
+}
+
+// AddListener starts serving connections accepted from l as well. The first
+// listener becomes Server.Listener and sets Server.Port for TCP addresses.
+func (s *Server) AddListener(l net.Listener) error {
+	if s.tlsConfig != nil {
+		l = tls.NewListener(l, s.tlsConfig)
+	}
+	s.mu.Lock()
+	if s.State == ClosedState || s.shuttingDown {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	if s.Listener == nil {
+		s.Listener = l
+		if addr, ok := l.Addr().(*net.TCPAddr); ok {
+			s.Port = addr.Port
+		}
+	}
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+	go s.listen(l)
+	return nil
+}
+
+// Addr returns the address of the first listener.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Listener == nil {
+		return nil
+	}
+	return s.Listener.Addr()
+}
+
+// Addrs returns the address of every listener in the order they were added.
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, l := range s.listeners {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
 func (s *Server) Close() error {
-	fmt.Printf("closing the server on port: %v\n", s.Port)
-	err := s.Listener.Close()
+	s.mu.Lock()
+	listeners := s.listeners
+	if len(listeners) == 0 && s.Listener != nil {
+		listeners = []net.Listener{s.Listener}
+	}
 	s.State = ClosedState
-	return err
+	s.mu.Unlock()
+
+	var errs []error
+	for _, l := range listeners {
+		fmt.Printf("closing the server on %v\n", l.Addr())
+		errs = append(errs, l.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	s.mu.Unlock()
+
+	err := s.Close()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.closeAllConns()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+func (s *Server) trackConn(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[*conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackConn(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+}
+
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.closeIfIdle()
+	}
+	return len(s.conns) == 0
+}
+
+func (s *Server) closeAllConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.close()
+	}
 }
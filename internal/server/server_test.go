@@ -1,12 +1,27 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"io"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"httpfromtcp/internal/headers"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 
@@ -15,6 +30,7 @@ import (
 )
 
 type mockConn struct {
+	mu              sync.Mutex
 	readData        *bytes.Buffer
 	writeData       *bytes.Buffer
 	closed          bool
@@ -38,6 +54,8 @@ func newMockConn(data string) *mockConn {
 }
 
 func (m *mockConn) Read(b []byte) (n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.closed {
 		return 0, errors.New("connection closed")
 	}
@@ -45,6 +63,8 @@ func (m *mockConn) Read(b []byte) (n int, err error) {
 }
 
 func (m *mockConn) Write(b []byte) (n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.closed {
 		return 0, errors.New("connection closed")
 	}
@@ -52,10 +72,24 @@ func (m *mockConn) Write(b []byte) (n int, err error) {
 }
 
 func (m *mockConn) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.closed = true
 	return m.closeFunc()
 }
 
+func (m *mockConn) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+func (m *mockConn) written() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.writeData.String()
+}
+
 func (m *mockConn) LocalAddr() net.Addr {
 	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}
 }
@@ -65,6 +99,8 @@ func (m *mockConn) RemoteAddr() net.Addr {
 }
 
 func (m *mockConn) SetDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.deadline = t
 	return m.setDeadlineFunc(t)
 }
@@ -78,6 +114,7 @@ func (m *mockConn) SetWriteDeadline(t time.Time) error {
 }
 
 type mockListener struct {
+	mu     sync.Mutex
 	conns  chan net.Conn
 	closed bool
 }
@@ -90,7 +127,10 @@ func newMockListener() *mockListener {
 }
 
 func (m *mockListener) Accept() (net.Conn, error) {
-	if m.closed {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
 		return nil, errors.New("listener closed")
 	}
 	conn, ok := <-m.conns
@@ -101,6 +141,8 @@ func (m *mockListener) Accept() (net.Conn, error) {
 }
 
 func (m *mockListener) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.closed {
 		return errors.New("listener already closed")
 	}
@@ -122,13 +164,109 @@ func TestServe_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, server)
 	assert.Equal(t, OpenState, server.State)
-	assert.Equal(t, 0, server.Port)
 	assert.NotNil(t, server.Listener)
+	addr, ok := server.Addr().(*net.TCPAddr)
+	require.True(t, ok)
+	assert.NotZero(t, addr.Port)
+	assert.Equal(t, addr.Port, server.Port)
 
 	err = server.Close()
 	require.NoError(t, err)
 }
 
+// get sends a GET for target on a new connection to addr and returns the body.
+func get(t *testing.T, addr net.Addr, target string) string {
+	t.Helper()
+	conn, err := net.Dial(addr.Network(), addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("GET " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, bufio.NewReader(conn))
+	return body
+}
+
+func TestServeAddr(t *testing.T) {
+	t.Run("all interfaces", func(t *testing.T) {
+		server, err := ServeAddr("tcp", "0.0.0.0:0", okHandler)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = server.Close() })
+		addr := server.Addr().(*net.TCPAddr)
+		assert.True(t, addr.IP.IsUnspecified())
+		assert.Equal(t, "/all", get(t, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: addr.Port}, "/all"))
+	})
+
+	t.Run("IPv6", func(t *testing.T) {
+		server, err := ServeAddr("tcp6", "[::1]:0", okHandler)
+		if err != nil {
+			t.Skipf("IPv6 loopback unavailable: %v", err)
+		}
+		t.Cleanup(func() { _ = server.Close() })
+		assert.Equal(t, "/v6", get(t, server.Addr(), "/v6"))
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		// Socket paths are limited to about 100 bytes, which t.TempDir can exceed.
+		dir, err := os.MkdirTemp("", "srv")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = os.RemoveAll(dir) })
+		path := filepath.Join(dir, "http.sock")
+
+		server, err := ServeAddr("unix", path, okHandler)
+		require.NoError(t, err)
+		assert.Equal(t, path, server.Addr().String())
+		assert.Equal(t, 0, server.Port)
+		assert.Equal(t, "/unix", get(t, server.Addr(), "/unix"))
+
+		require.NoError(t, server.Close())
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("invalid network", func(t *testing.T) {
+		_, err := ServeAddr("carrier-pigeon", "coop", okHandler)
+		assert.Error(t, err)
+	})
+}
+
+func TestServeListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := ServeListener(l, okHandler)
+	require.NoError(t, err)
+
+	assert.Equal(t, l.Addr(), server.Addr())
+	assert.Equal(t, "/own", get(t, l.Addr(), "/own"))
+
+	require.NoError(t, server.Close())
+	_, err = l.Accept()
+	assert.Error(t, err)
+}
+
+func TestServer_MultipleListeners(t *testing.T) {
+	server, err := ServeAddr("tcp", "127.0.0.1:0", okHandler)
+	require.NoError(t, err)
+	first := server.Addr()
+
+	second, err := server.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.Equal(t, []net.Addr{first, second}, server.Addrs())
+	assert.Equal(t, first, server.Addr())
+
+	assert.Equal(t, "/first", get(t, first, "/first"))
+	assert.Equal(t, "/second", get(t, second, "/second"))
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	for _, addr := range []net.Addr{first, second} {
+		_, err := net.Dial(addr.Network(), addr.String())
+		assert.Error(t, err)
+	}
+
+	_, err = server.Listen("tcp", "127.0.0.1:0")
+	assert.ErrorIs(t, err, ErrServerClosed)
+}
+
 func TestServe_InvalidPort(t *testing.T) {
 	_, err := Serve(-1, nil)
 	assert.Error(t, err)
@@ -156,7 +294,7 @@ func TestHandle_ValidRequest(t *testing.T) {
 			assert.Equal(t, "GET", req.RequestLine.Method)
 			assert.Equal(t, "/", req.RequestLine.RequestTarget)
 			assert.Equal(t, "1.1", req.RequestLine.HttpVersion)
-			assert.Equal(t, "localhost", req.Headers["host"])
+			assert.Equal(t, "localhost", req.Headers.Get("host"))
 
 			err := w.WriteStatusLine(response.StatusOK)
 			require.NoError(t, err)
@@ -169,11 +307,11 @@ func TestHandle_ValidRequest(t *testing.T) {
 
 	server.handle(conn)
 
-	assert.True(t, conn.closed)
+	assert.True(t, conn.isClosed())
 
 	assert.True(t, !conn.deadline.IsZero())
 
-	responseData := conn.writeData.String()
+	responseData := conn.written()
 	assert.Contains(t, responseData, "HTTP/1.1 200 OK")
 }
 
@@ -190,7 +328,96 @@ func TestHandle_InvalidRequest(t *testing.T) {
 
 	server.handle(conn)
 
-	assert.True(t, conn.closed)
+	assert.True(t, conn.isClosed())
+	assert.Contains(t, conn.written(), "HTTP/1.1 400 Bad Request\r\n")
+	assert.Contains(t, conn.written(), "Connection: close\r\n")
+}
+
+func TestHandle_ParseErrorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{"Unsupported version", "GET / HTTP/2.0\r\nHost: localhost\r\n\r\n", "HTTP/1.1 505 HTTP Version Not Supported\r\n"},
+		{"Transfer coding", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip\r\n\r\n", "HTTP/1.1 501 Not Implemented\r\n"},
+		{"Long target", "GET /" + strings.Repeat("a", 10000) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", "HTTP/1.1 414 URI Too Long\r\n"},
+		{"Malformed header", "GET / HTTP/1.1\r\nHost localhost\r\n\r\n", "HTTP/1.1 400 Bad Request\r\n"},
+		{"Missing Host", "GET / HTTP/1.1\r\n\r\n", "HTTP/1.1 400 Bad Request\r\n"},
+		{"Duplicate Host", "GET / HTTP/1.1\r\nHost: a.test\r\nHost: b.test\r\n\r\n", "HTTP/1.1 400 Bad Request\r\n"},
+		{"Invalid Host", "GET / HTTP/1.1\r\nHost: a b\r\n\r\n", "HTTP/1.1 400 Bad Request\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newMockConn(tt.request)
+			server := &Server{
+				State: OpenState,
+				handler: func(w *response.Writer, req *request.Request) {
+					t.Fatal("Handler should not be called for invalid request")
+				},
+			}
+
+			server.handle(conn)
+
+			assert.True(t, conn.isClosed())
+			assert.True(t, strings.HasPrefix(conn.written(), tt.expected), conn.written())
+		})
+	}
+}
+
+func TestHandle_ChunkedRequestBody(t *testing.T) {
+	conn := newMockConn("POST /upload HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"4\r\nWiki\r\n5\r\npedia\r\n0\r\nX-Sum: 9\r\n\r\n")
+	var body, trailer string
+	server := &Server{
+		State: OpenState,
+		handler: func(w *response.Writer, req *request.Request) {
+			data, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			body = string(data)
+			trailer = req.Trailers.Get("x-sum")
+			okHandler(w, req)
+		},
+	}
+
+	server.handle(conn)
+
+	assert.Equal(t, "Wikipedia", body)
+	assert.Equal(t, "9", trailer)
+	assert.Contains(t, conn.written(), "HTTP/1.1 200 OK\r\n")
+}
+
+func TestHandle_Limits(t *testing.T) {
+	limits := request.Limits{MaxHeaderCount: 2, MaxBodyBytes: 4}
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{"Oversized header block", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 70*1024) + "\r\n\r\n", "HTTP/1.1 431 Request Header Fields Too Large\r\n"},
+		{"Too many headers", "GET / HTTP/1.1\r\nHost: localhost\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n", "HTTP/1.1 431 Request Header Fields Too Large\r\n"},
+		{"Content-Length over limit", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nabcde", "HTTP/1.1 413 Content Too Large\r\n"},
+		{"Chunked body over limit", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n3\r\ndef\r\n0\r\n\r\n", "HTTP/1.1 413 Content Too Large\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newMockConn(tt.request)
+			server := &Server{
+				State:  OpenState,
+				limits: limits,
+				handler: func(w *response.Writer, req *request.Request) {
+					_, _ = io.ReadAll(req.Body)
+				},
+			}
+
+			server.handle(conn)
+
+			assert.True(t, conn.isClosed())
+			assert.True(t, strings.HasPrefix(conn.written(), tt.expected), conn.written())
+		})
+	}
 }
 
 func TestHandle_HandlerError(t *testing.T) {
@@ -208,7 +435,7 @@ func TestHandle_HandlerError(t *testing.T) {
 		server.handle(conn)
 	})
 
-	assert.True(t, conn.closed)
+	assert.True(t, conn.isClosed())
 }
 
 func TestHandle_ConnectionCloseError(t *testing.T) {
@@ -256,7 +483,7 @@ func TestHandle_SetDeadlineError(t *testing.T) {
 		server.handle(conn)
 	})
 
-	assert.True(t, conn.closed)
+	assert.True(t, conn.isClosed())
 }
 
 func TestListen_AcceptConnections(t *testing.T) {
@@ -267,7 +494,7 @@ func TestListen_AcceptConnections(t *testing.T) {
 		handler:  func(w *response.Writer, req *request.Request) {},
 	}
 
-	go server.listen()
+	go server.listen(listener)
 
 	conn := newMockConn("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
 	listener.addConn(conn)
@@ -276,7 +503,7 @@ func TestListen_AcceptConnections(t *testing.T) {
 
 	_ = listener.Close()
 
-	assert.True(t, conn.closed)
+	assert.True(t, conn.isClosed())
 }
 
 func TestListen_ListenerError(t *testing.T) {
@@ -290,10 +517,715 @@ func TestListen_ListenerError(t *testing.T) {
 	_ = listener.Close()
 
 	assert.NotPanics(t, func() {
-		server.listen()
+		server.listen(listener)
 	})
 }
 
+func okHandler(w *response.Writer, req *request.Request) {
+	_ = w.WriteStatusLine(response.StatusOK)
+	_ = w.WriteHeaders(response.GetDefaultHeaders(len(req.RequestLine.RequestTarget)))
+	_, _ = w.WriteBody([]byte(req.RequestLine.RequestTarget))
+}
+
+func dialServer(t *testing.T, h Handler, opts ...Option) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	server, err := Serve(0, h, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	return dial(t, server)
+}
+
+func dial(t *testing.T, server *Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	return conn, bufio.NewReader(conn)
+}
+
+// readResponse reads a single Content-Length framed response and returns its raw head and body
+func readResponse(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var head strings.Builder
+	contentLength := 0
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		head.WriteString(line)
+		if line == "\r\n" {
+			break
+		}
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && strings.EqualFold(k, "Content-Length") {
+			contentLength, err = strconv.Atoi(strings.TrimSpace(v))
+			require.NoError(t, err)
+		}
+	}
+	body := make([]byte, contentLength)
+	_, err := io.ReadFull(r, body)
+	require.NoError(t, err)
+	return head.String(), string(body)
+}
+
+func TestHandle_KeepAlive(t *testing.T) {
+	conn, r := dialServer(t, okHandler)
+
+	for _, target := range []string{"/first", "/second", "/third"} {
+		_, err := conn.Write([]byte("GET " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		head, body := readResponse(t, r)
+		assert.Contains(t, head, "HTTP/1.1 200 OK")
+		assert.NotContains(t, head, "Connection: close")
+		assert.Equal(t, target, body)
+	}
+}
+
+func TestHandle_ConnectionClose(t *testing.T) {
+	conn, r := dialServer(t, okHandler)
+
+	_, err := conn.Write([]byte("GET /bye HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "Connection: close")
+	assert.Equal(t, "/bye", body)
+
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestHandle_HeadOmitsBody(t *testing.T) {
+	conn, r := dialServer(t, okHandler)
+
+	_, err := conn.Write([]byte("HEAD /page HTTP/1.1\r\nHost: localhost\r\n\r\nGET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", line)
+	for line != "\r\n" {
+		line, err = r.ReadString('\n')
+		require.NoError(t, err)
+	}
+	_, body := readResponse(t, r)
+	assert.Equal(t, "/next", body)
+}
+
+func TestHandle_Middleware(t *testing.T) {
+	addHeader := func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			w.Header().Set("X-Served-By", "middleware")
+			next(w, req)
+		}
+	}
+	conn, r := dialServer(t, okHandler, WithMiddleware(addHeader))
+
+	_, err := conn.Write([]byte("GET /wrapped HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "X-Served-By: middleware\r\n")
+	assert.Equal(t, "/wrapped", body)
+}
+
+func TestHandle_ImplicitResponses(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		switch req.Target.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("implicit"))
+		case "/stream":
+			_, _ = w.Write([]byte("part"))
+			_ = w.Flush()
+			_, _ = w.Write([]byte("s"))
+		}
+	})
+
+	_, err := conn.Write([]byte("GET /text HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	head, body := readResponse(t, r)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+	assert.Contains(t, head, "Content-Length: 8\r\n")
+	assert.Contains(t, head, "Date: ")
+	assert.Equal(t, "implicit", body)
+
+	_, err = conn.Write([]byte("GET /empty HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	head, body = readResponse(t, r)
+	assert.Contains(t, head, "Content-Length: 0\r\n")
+	assert.Empty(t, body)
+
+	_, err = conn.Write([]byte("GET /stream HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	head, _ = readResponse(t, r)
+	assert.Contains(t, head, "Transfer-Encoding: chunked\r\n")
+	chunked := make([]byte, len("4\r\npart\r\n1\r\ns\r\n0\r\n\r\n"))
+	_, err = io.ReadFull(r, chunked)
+	require.NoError(t, err)
+	assert.Equal(t, "4\r\npart\r\n1\r\ns\r\n0\r\n\r\n", string(chunked))
+
+	_, err = conn.Write([]byte("GET /text HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "implicit", body)
+}
+
+func TestHandle_ResponseTrailers(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		if req.Target.Path != "/trailers" {
+			okHandler(w, req)
+			return
+		}
+		h := headers.NewHeaders()
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "X-Sum")
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(h)
+		_, _ = w.WriteChunk([]byte("abc"))
+		_ = w.WriteChunkedBodyDone()
+		trailers := headers.NewHeaders()
+		trailers.Set("X-Sum", "6")
+		_ = w.WriteTrailers(trailers)
+	})
+
+	_, err := conn.Write([]byte("GET /trailers HTTP/1.1\r\nHost: localhost\r\n\r\nGET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	head, _ := readResponse(t, r)
+	assert.Contains(t, head, "Trailer: X-Sum\r\n")
+	body := make([]byte, len("3\r\nabc\r\n0\r\nX-Sum: 6\r\n\r\n"))
+	_, err = io.ReadFull(r, body)
+	require.NoError(t, err)
+	assert.Equal(t, "3\r\nabc\r\n0\r\nX-Sum: 6\r\n\r\n", string(body))
+
+	head, next := readResponse(t, r)
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+	assert.Equal(t, "/next", next)
+}
+
+func TestHandle_UnframedResponseCloses(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(headers.NewHeaders())
+		_, _ = w.WriteBody([]byte("until close"))
+	})
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nuntil close"))
+}
+
+func TestHandle_TruncatedResponseCloses(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(10))
+		_, _ = w.WriteBody([]byte("short"))
+	})
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\nGET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nshort"))
+	assert.Equal(t, 1, strings.Count(string(data), "HTTP/1.1 200"))
+}
+
+func echoBodyHandler(w *response.Writer, req *request.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+func TestHandle_AbortedResponseCloses(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 5000))
+		w.Abort()
+	})
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Transfer-Encoding: chunked\r\n")
+	assert.False(t, strings.HasSuffix(string(data), "0\r\n\r\n"))
+}
+
+func TestHandle_ExpectContinue(t *testing.T) {
+	conn, r := dialServer(t, echoBodyHandler)
+
+	_, err := conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n"))
+	require.NoError(t, err)
+	interim, _ := readResponse(t, r)
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\n", interim)
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 200 OK")
+	assert.NotContains(t, head, "Connection: close")
+	assert.Equal(t, "hello", body)
+
+	// The connection stays usable after the expectation was met.
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nagain"))
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "again", body)
+}
+
+func TestHandle_ExpectContinueBodyNotRead(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		w.SetStatus(response.StatusForbidden)
+	})
+
+	_, err := conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n"))
+	require.NoError(t, err)
+	head, _ := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 403 Forbidden")
+	assert.Contains(t, head, "Connection: close")
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestHandle_ExpectationFailed(t *testing.T) {
+	conn, r := dialServer(t, echoBodyHandler)
+
+	_, err := conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nExpect: something-else\r\nContent-Length: 5\r\n\r\n"))
+	require.NoError(t, err)
+	head, _ := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 417 Expectation Failed")
+}
+
+func TestHandle_EarlyHints(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		h := headers.NewHeaders()
+		h.Add("Link", "</app.js>; rel=preload; as=script")
+		_ = w.WriteInformational(response.StatusEarlyHints, h)
+		_, _ = w.Write([]byte("page"))
+	})
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	interim, _ := readResponse(t, r)
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\nLink: </app.js>; rel=preload; as=script\r\n\r\n", interim)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 200 OK")
+	assert.Equal(t, "page", body)
+}
+
+func TestHandle_HTTP10(t *testing.T) {
+	t.Run("closes by default", func(t *testing.T) {
+		conn, r := dialServer(t, okHandler)
+		_, err := conn.Write([]byte("GET /old HTTP/1.0\r\n\r\n"))
+		require.NoError(t, err)
+		head, body := readResponse(t, r)
+		assert.Contains(t, head, "HTTP/1.1 200 OK")
+		assert.Contains(t, head, "Connection: close")
+		assert.Equal(t, "/old", body)
+		_, err = r.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("keep-alive", func(t *testing.T) {
+		conn, r := dialServer(t, okHandler)
+		for _, target := range []string{"/first", "/second"} {
+			_, err := conn.Write([]byte("GET " + target + " HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+			require.NoError(t, err)
+			head, body := readResponse(t, r)
+			assert.Contains(t, head, "Connection: keep-alive")
+			assert.Equal(t, target, body)
+		}
+	})
+
+	t.Run("streamed response is not chunked", func(t *testing.T) {
+		conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+			_, _ = w.Write([]byte("streamed"))
+			_ = w.Flush()
+		})
+		_, err := conn.Write([]byte("GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "Transfer-Encoding")
+		assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nstreamed"))
+	})
+
+	t.Run("no interim responses", func(t *testing.T) {
+		conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+			h := headers.NewHeaders()
+			h.Add("Link", "</app.js>; rel=preload; as=script")
+			_ = w.WriteInformational(response.StatusEarlyHints, h)
+			okHandler(w, req)
+		})
+		_, err := conn.Write([]byte("GET /hints HTTP/1.0\r\n\r\n"))
+		require.NoError(t, err)
+		head, body := readResponse(t, r)
+		assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"), head)
+		assert.NotContains(t, head, "Link")
+		assert.Equal(t, "/hints", body)
+	})
+
+	t.Run("other major version", func(t *testing.T) {
+		conn, r := dialServer(t, okHandler)
+		_, err := conn.Write([]byte("GET / HTTP/2.0\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		head, _ := readResponse(t, r)
+		assert.Contains(t, head, "HTTP/1.1 505 HTTP Version Not Supported")
+	})
+}
+
+func TestHandle_StrictAndLenientParsing(t *testing.T) {
+	const bareLF = "GET /legacy HTTP/1.1\nHost: localhost\n\n"
+
+	conn, r := dialServer(t, okHandler)
+	_, err := conn.Write([]byte(bareLF))
+	require.NoError(t, err)
+	head, _ := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 400 Bad Request")
+
+	conn, r = dialServer(t, okHandler, WithLenientParsing())
+	_, err = conn.Write([]byte(bareLF))
+	require.NoError(t, err)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 200 OK")
+	assert.Equal(t, "/legacy", body)
+
+	// Both framings are tolerated but the connection is not reused
+	_, err = conn.Write([]byte("POST /both HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n"))
+	require.NoError(t, err)
+	head, body = readResponse(t, r)
+	assert.Contains(t, head, "Connection: close")
+	assert.Equal(t, "/both", body)
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// selfSignedCertificate returns a certificate for names, generated for the test.
+func selfSignedCertificate(t *testing.T, names ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestHandle_TLS(t *testing.T) {
+	cert := selfSignedCertificate(t, "example.test")
+	server, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		body := "plain"
+		if req.TLS != nil {
+			body = req.TLS.ServerName
+		}
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		_, _ = w.WriteBody([]byte(body))
+	}, WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "example.test"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	r := bufio.NewReader(conn)
+
+	for i := 0; i < 2; i++ {
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.test\r\n\r\n"))
+		require.NoError(t, err)
+		_, body := readResponse(t, r)
+		assert.Equal(t, "example.test", body)
+	}
+}
+
+func TestHandle_TLSClientCertificate(t *testing.T) {
+	serverCert := selfSignedCertificate(t, "example.test")
+	clientCert := selfSignedCertificate(t, "client.example.test")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+
+	server, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		body := "anonymous"
+		if chain := req.VerifiedChain(); len(chain) > 0 {
+			body = chain[0].DNSNames[0]
+		}
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		_, _ = w.WriteBody([]byte(body))
+	}, WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCAs,
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.Leaf)
+	for _, tt := range []struct {
+		certs    []tls.Certificate
+		expected string
+	}{
+		{nil, "anonymous"},
+		{[]tls.Certificate{clientCert}, "client.example.test"},
+	} {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+			RootCAs:      roots,
+			ServerName:   "example.test",
+			Certificates: tt.certs,
+		})
+		require.NoError(t, err)
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.test\r\n\r\n"))
+		require.NoError(t, err)
+		_, body := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, tt.expected, body)
+		_ = conn.Close()
+	}
+}
+
+func TestHandle_TLSRejectsPlaintext(t *testing.T) {
+	conn, r := dialServer(t, okHandler, WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t, "example.test")},
+	}))
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	reply, _ := io.ReadAll(r)
+	assert.NotContains(t, string(reply), "HTTP/1.1 200")
+}
+
+func TestHandle_IdleTimeout(t *testing.T) {
+	conn, r := dialServer(t, okHandler, WithIdleTimeout(50*time.Millisecond))
+
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, r)
+	assert.Equal(t, "/", body)
+
+	start := time.Now()
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestHandle_PipelinedRequests(t *testing.T) {
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		okHandler(w, req)
+	})
+
+	_, err := conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"POST /body HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\n\r\nbody" +
+		"GET /last HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	for _, expected := range []string{"/slow", "/body", "/last"} {
+		_, body := readResponse(t, r)
+		assert.Equal(t, expected, body)
+	}
+}
+
+func TestHandle_PipelineCap(t *testing.T) {
+	var pipelined strings.Builder
+	for i := 0; i < 5; i++ {
+		pipelined.WriteString("GET /" + strconv.Itoa(i) + " HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	}
+	mock := newMockConn(pipelined.String())
+
+	var c *conn
+	maxInFlight := 0
+	server := &Server{
+		State:                OpenState,
+		maxPipelinedRequests: 2,
+		handler: func(w *response.Writer, req *request.Request) {
+			time.Sleep(5 * time.Millisecond)
+			c.mu.Lock()
+			maxInFlight = max(maxInFlight, c.inFlight)
+			c.mu.Unlock()
+			okHandler(w, req)
+		},
+	}
+	c = server.newConn(mock)
+	go c.readRequests()
+	c.serveRequests()
+	c.close()
+
+	assert.Equal(t, 2, maxInFlight)
+	r := bufio.NewReader(strings.NewReader(mock.written()))
+	for i := 0; i < 5; i++ {
+		_, body := readResponse(t, r)
+		assert.Equal(t, "/"+strconv.Itoa(i), body)
+	}
+}
+
+func TestHandle_PipelineStopsAfterConnectionClose(t *testing.T) {
+	mock := newMockConn("GET /a HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n" +
+		"GET /b HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	var targets []string
+	server := &Server{
+		State: OpenState,
+		handler: func(w *response.Writer, req *request.Request) {
+			targets = append(targets, req.RequestLine.RequestTarget)
+			okHandler(w, req)
+		},
+	}
+
+	server.handle(mock)
+
+	assert.Equal(t, []string{"/a"}, targets)
+	assert.True(t, mock.isClosed())
+}
+
+func TestHandle_StreamsRequestBody(t *testing.T) {
+	started := make(chan struct{})
+	conn, r := dialServer(t, func(w *response.Writer, req *request.Request) {
+		close(started)
+		data, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(data)))
+		_, _ = w.WriteBody(data)
+	})
+
+	_, err := conn.Write([]byte("POST /echo HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\n"))
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not invoked before the body arrived")
+	}
+
+	_, err = conn.Write([]byte("hello world"))
+	require.NoError(t, err)
+	_, body := readResponse(t, r)
+	assert.Equal(t, "hello world", body)
+}
+
+func TestHandle_UnreadBodyIsDiscarded(t *testing.T) {
+	conn, r := dialServer(t, okHandler)
+
+	_, err := conn.Write([]byte("POST /skip HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nabcde" +
+		"GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	_, body := readResponse(t, r)
+	assert.Equal(t, "/skip", body)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/next", body)
+}
+
+func TestHandle_LargeUnreadBodyClosesConnection(t *testing.T) {
+	conn, r := dialServer(t, okHandler)
+
+	_, err := conn.Write([]byte("POST /big HTTP/1.1\r\nHost: localhost\r\nContent-Length: " +
+		strconv.Itoa(maxDiscardBytes*2) + "\r\n\r\n"))
+	require.NoError(t, err)
+	go func() {
+		_, _ = conn.Write(bytes.Repeat([]byte("a"), maxDiscardBytes*2))
+	}()
+
+	_, body := readResponse(t, r)
+	assert.Equal(t, "/big", body)
+	// the server closes instead of draining; depending on timing that is an EOF or a reset
+	data, _ := io.ReadAll(r)
+	assert.Empty(t, data)
+}
+
+func TestShutdown_WaitsForInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		close(started)
+		<-release
+		okHandler(w, req)
+	})
+	require.NoError(t, err)
+	conn, r := dial(t, server)
+
+	_, err = conn.Write([]byte("GET /busy HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdownErr:
+		t.Fatal("Shutdown returned before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	head, body := readResponse(t, r)
+	assert.Contains(t, head, "Connection: close")
+	assert.Equal(t, "/busy", body)
+	require.NoError(t, <-shutdownErr)
+	assert.Equal(t, ClosedState, server.State)
+
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestShutdown_ClosesIdleConnections(t *testing.T) {
+	server, err := Serve(0, okHandler)
+	require.NoError(t, err)
+	conn, r := dial(t, server)
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	readResponse(t, r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = net.Dial("tcp", server.Listener.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestShutdown_ForceClosesOnContextExpiry(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	server, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		close(started)
+		<-release
+	})
+	require.NoError(t, err)
+	conn, r := dial(t, server)
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = r.ReadByte()
+	assert.Error(t, err)
+}
+
 func TestServerState(t *testing.T) {
 	assert.Equal(t, 0, int(OpenState))
 	assert.Equal(t, 1, int(ClosedState))
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"crypto/tls"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 	"net"
+	"sync"
+	"time"
 )
 
 type ServerState int
@@ -18,6 +21,20 @@ type Server struct {
 	Port     int
 	Listener net.Listener
 	handler  Handler
+
+	readTimeout          time.Duration
+	writeTimeout         time.Duration
+	idleTimeout          time.Duration
+	maxPipelinedRequests int
+	limits               request.Limits
+	lenient              bool
+	tlsConfig            *tls.Config
+	middleware           []Middleware
+
+	mu           sync.Mutex
+	listeners    []net.Listener
+	conns        map[*conn]struct{}
+	shuttingDown bool
 }
 
 type HandlerError struct {
@@ -26,3 +43,5 @@ type HandlerError struct {
 }
 
 type Handler func(w *response.Writer, req *request.Request)
+
+type Middleware func(Handler) Handler
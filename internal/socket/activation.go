@@ -0,0 +1,64 @@
+package socket
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// Activated returns the listeners passed by systemd socket activation, in
+// LISTEN_FDS order, or none when the process was not socket activated. The
+// environment variables are cleared so child processes do not claim them.
+func Activated() ([]net.Listener, error) {
+	pid, fds := os.Getenv(listenPIDEnv), os.Getenv(listenFDsEnv)
+	_ = os.Unsetenv(listenPIDEnv)
+	_ = os.Unsetenv(listenFDsEnv)
+	_ = os.Unsetenv(listenFDNamesEnv)
+	if pid == "" || fds == "" {
+		return nil, nil
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := parseCount(listenFDsEnv, fds)
+	if err != nil {
+		return nil, err
+	}
+	return fileListeners(listenFDsStart, count)
+}
+
+func parseCount(name, value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return count, nil
+}
+
+// fileListeners wraps count descriptors starting at first. The descriptors
+// are duplicated by net.FileListener and closed afterwards.
+func fileListeners(first, count int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, count)
+	for fd := first; fd < first+count; fd++ {
+		f := os.NewFile(uintptr(fd), "listener-"+strconv.Itoa(fd))
+		if f == nil {
+			closeAll(listeners)
+			return nil, fmt.Errorf("descriptor %d is not open", fd)
+		}
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			closeAll(listeners)
+			return nil, fmt.Errorf("descriptor %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+}
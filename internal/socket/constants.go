@@ -0,0 +1,18 @@
+package socket
+
+const (
+	// listenFDsStart is the first inherited descriptor, after stdin, stdout
+	// and stderr.
+	listenFDsStart = 3
+
+	listenPIDEnv     = "LISTEN_PID"
+	listenFDsEnv     = "LISTEN_FDS"
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+
+	// handoffFDsEnv carries the listener count to a child started by Handoff.
+	// It is separate from LISTEN_FDS because the child's PID is not known
+	// before it starts.
+	handoffFDsEnv = "HTTPFROMTCP_LISTEN_FDS"
+
+	readySignal = 'R'
+)
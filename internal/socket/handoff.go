@@ -0,0 +1,142 @@
+package socket
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Restart starts the running executable again with the same arguments and
+// environment and hands it listeners; see Handoff.
+func Restart(listeners []net.Listener, timeout time.Duration) (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return Handoff(cmd, listeners, timeout)
+}
+
+// Handoff starts cmd with duplicates of listeners as descriptors 3 and up and
+// waits until it calls the ready function returned by Inherited. The caller
+// keeps accepting until then, and afterwards may close its listeners and
+// drain: the sockets stay open in the child, so no connection is refused. If
+// the child exits or is not ready within timeout, it is killed and an error
+// returned.
+func Handoff(cmd *exec.Cmd, listeners []net.Listener, timeout time.Duration) (*os.Process, error) {
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		fl, ok := l.(filer)
+		if !ok {
+			return nil, fmt.Errorf("listener on %v has no file descriptor", l.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = readyReader.Close() }()
+	files = append(files, readyWriter)
+
+	cmd.ExtraFiles = files
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, handoffFDsEnv+"="+strconv.Itoa(len(listeners)))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// Only the child may hold the write end, so its exit ends the read.
+	files = files[:len(files)-1]
+	if err := readyWriter.Close(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("handoff to pid %d: %w", cmd.Process.Pid, err)
+	}
+
+	if err := waitReady(readyReader, timeout); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("handoff to pid %d: %w", cmd.Process.Pid, err)
+	}
+	// The child owns the socket paths now.
+	for _, l := range listeners {
+		if unix, ok := l.(*net.UnixListener); ok {
+			unix.SetUnlinkOnClose(false)
+		}
+	}
+	return cmd.Process, nil
+}
+
+func waitReady(r *os.File, timeout time.Duration) error {
+	if err := r.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	b := make([]byte, 1)
+	if _, err := r.Read(b); err != nil {
+		return fmt.Errorf("child not ready: %w", err)
+	}
+	if b[0] != readySignal {
+		return fmt.Errorf("unexpected readiness byte %q", b[0])
+	}
+	return nil
+}
+
+// Inherited returns the listeners passed by a parent's Handoff, or none when
+// the process was not started that way. Calling ready once the listeners are
+// being served lets the parent stop accepting; it does nothing when nothing
+// was inherited.
+func Inherited() (listeners []net.Listener, ready func() error, err error) {
+	value := os.Getenv(handoffFDsEnv)
+	_ = os.Unsetenv(handoffFDsEnv)
+	if value == "" {
+		return nil, func() error { return nil }, nil
+	}
+	count, err := parseCount(handoffFDsEnv, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	listeners, err = fileListeners(listenFDsStart, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Socket paths belong to the last process in the chain, which removes
+	// them when it closes; Handoff turns this off again before passing on.
+	for _, l := range listeners {
+		if unix, ok := l.(*net.UnixListener); ok {
+			unix.SetUnlinkOnClose(true)
+		}
+	}
+	readyFile := os.NewFile(uintptr(listenFDsStart+count), "ready")
+	if readyFile == nil {
+		closeAll(listeners)
+		return nil, nil, fmt.Errorf("readiness descriptor %d is not open", listenFDsStart+count)
+	}
+	ready = func() error {
+		if _, err := readyFile.Write([]byte{readySignal}); err != nil {
+			_ = readyFile.Close()
+			return err
+		}
+		return readyFile.Close()
+	}
+	return listeners, ready, nil
+}
@@ -0,0 +1,194 @@
+package socket
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helperEnv = "SOCKET_TEST_HELPER"
+
+// TestHelperProcess is run as a child process by the tests below. It takes
+// its listener from the environment and answers one connection with its
+// name.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+	var listeners []net.Listener
+	ready := func() error { return nil }
+	var err error
+	switch mode {
+	case "activated":
+		listeners, err = Activated()
+	case "handoff":
+		listeners, ready, err = Inherited()
+	case "exit":
+		os.Exit(3)
+	}
+	if err != nil || len(listeners) != 1 {
+		fmt.Fprintf(os.Stderr, "helper %s: %d listeners, %v\n", mode, len(listeners), err)
+		os.Exit(2)
+	}
+	if err := ready(); err != nil {
+		os.Exit(2)
+	}
+	conn, err := listeners[0].Accept()
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Fprintf(conn, "%s %s\n", mode, os.Getenv(listenFDsEnv)+os.Getenv(handoffFDsEnv))
+	conn.Close()
+	os.Exit(0)
+}
+
+func helperCommand(mode string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+mode)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func listenTCP(t *testing.T) *net.TCPListener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	return l.(*net.TCPListener)
+}
+
+func readLine(t *testing.T, addr net.Addr) string {
+	t.Helper()
+	conn, err := net.Dial(addr.Network(), addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	return line
+}
+
+func TestActivated_NotActivated(t *testing.T) {
+	t.Setenv(listenPIDEnv, "")
+	t.Setenv(listenFDsEnv, "")
+	listeners, err := Activated()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+}
+
+func TestActivated_OtherProcess(t *testing.T) {
+	t.Setenv(listenPIDEnv, strconv.Itoa(os.Getpid()+1))
+	t.Setenv(listenFDsEnv, "1")
+	listeners, err := Activated()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+	assert.Empty(t, os.Getenv(listenFDsEnv))
+}
+
+func TestActivated_InvalidCount(t *testing.T) {
+	t.Setenv(listenPIDEnv, strconv.Itoa(os.Getpid()))
+	t.Setenv(listenFDsEnv, "many")
+	_, err := Activated()
+	assert.Error(t, err)
+}
+
+func TestActivated(t *testing.T) {
+	l := listenTCP(t)
+	f, err := l.File()
+	require.NoError(t, err)
+	defer f.Close()
+
+	// The shell's PID becomes the test binary's through exec, as with
+	// systemd, which sets LISTEN_PID just before exec.
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`, os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperEnv+"=activated", listenFDsEnv+"=1")
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	require.NoError(t, cmd.Start())
+
+	// The variables are cleared once read.
+	assert.Equal(t, "activated \n", readLine(t, l.Addr()))
+	require.NoError(t, cmd.Wait())
+}
+
+func TestHandoff(t *testing.T) {
+	l := listenTCP(t)
+	cmd := helperCommand("handoff")
+	process, err := Handoff(cmd, []net.Listener{l}, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, cmd.Process.Pid, process.Pid)
+
+	// The parent stops accepting; connections keep reaching the child.
+	addr := l.Addr()
+	require.NoError(t, l.Close())
+	assert.Equal(t, "handoff \n", readLine(t, addr))
+	require.NoError(t, cmd.Wait())
+}
+
+func TestHandoff_UnixSocketSurvivesParentClose(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	l, err := net.Listen("unix", dir+"/http.sock")
+	require.NoError(t, err)
+
+	cmd := helperCommand("handoff")
+	_, err = Handoff(cmd, []net.Listener{l}, 5*time.Second)
+	require.NoError(t, err)
+
+	addr := l.Addr()
+	require.NoError(t, l.Close())
+	assert.Equal(t, "handoff \n", readLine(t, addr))
+	require.NoError(t, cmd.Wait())
+}
+
+func TestHandoff_ChildFails(t *testing.T) {
+	l := listenTCP(t)
+	_, err := Handoff(helperCommand("exit"), []net.Listener{l}, 5*time.Second)
+	assert.Error(t, err)
+
+	// The parent still owns a working listener.
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			fmt.Fprintln(conn, "parent")
+			conn.Close()
+		}
+	}()
+	assert.Equal(t, "parent\n", readLine(t, l.Addr()))
+}
+
+func TestHandoff_Timeout(t *testing.T) {
+	l := listenTCP(t)
+	cmd := exec.Command("/bin/sleep", "10")
+	start := time.Now()
+	_, err := Handoff(cmd, []net.Listener{l}, 50*time.Millisecond)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestHandoff_ListenerWithoutFile(t *testing.T) {
+	_, err := Handoff(helperCommand("handoff"), []net.Listener{fakeListener{}}, time.Second)
+	assert.Error(t, err)
+}
+
+func TestInherited_NotInherited(t *testing.T) {
+	t.Setenv(handoffFDsEnv, "")
+	listeners, ready, err := Inherited()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+	assert.NoError(t, ready())
+}
+
+type fakeListener struct{ net.Listener }
+
+func (fakeListener) Addr() net.Addr { return &net.TCPAddr{} }
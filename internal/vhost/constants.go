@@ -0,0 +1,8 @@
+package vhost
+
+const (
+	wildcardPrefix = "*."
+	labelSeparator = "."
+	portSeparator  = ":"
+	notFoundBody   = "Not Found"
+)
@@ -0,0 +1,11 @@
+package vhost
+
+import "httpfromtcp/internal/server"
+
+type Option func(*Dispatcher)
+
+func WithFallback(h server.Handler) Option {
+	return func(d *Dispatcher) {
+		d.fallback = h
+	}
+}
@@ -0,0 +1,17 @@
+package vhost
+
+import "httpfromtcp/internal/server"
+
+type Dispatcher struct {
+	exact     map[string]server.Handler
+	wildcards []wildcard
+	fallback  server.Handler
+}
+
+// wildcard matches hosts ending in suffix (which starts with "."), on any
+// port when port is empty.
+type wildcard struct {
+	suffix  string
+	port    string
+	handler server.Handler
+}
@@ -0,0 +1,103 @@
+package vhost
+
+import (
+	"cmp"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"slices"
+	"strings"
+)
+
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{exact: make(map[string]server.Handler)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Handle registers h for pattern, which is a host name optionally prefixed
+// with "*." to match any subdomain and optionally followed by ":port" to
+// match only that port.
+func (d *Dispatcher) Handle(pattern string, h server.Handler) {
+	host, port, _ := request.SplitHostPort(pattern)
+	host = normalizeHost(host)
+	if host == "" || strings.Contains(strings.TrimPrefix(host, wildcardPrefix), "*") {
+		panic(fmt.Sprintf("vhost: invalid host pattern %q", pattern))
+	}
+
+	if suffix, ok := strings.CutPrefix(host, wildcardPrefix); ok {
+		for _, w := range d.wildcards {
+			if w.suffix == labelSeparator+suffix && w.port == port {
+				panic(fmt.Sprintf("vhost: duplicate host pattern %q", pattern))
+			}
+		}
+		d.wildcards = append(d.wildcards, wildcard{suffix: labelSeparator + suffix, port: port, handler: h})
+		// Longer suffixes are more specific; at equal length a port-qualified
+		// pattern wins.
+		slices.SortStableFunc(d.wildcards, func(a, b wildcard) int {
+			if c := cmp.Compare(len(b.suffix), len(a.suffix)); c != 0 {
+				return c
+			}
+			return cmp.Compare(len(b.port), len(a.port))
+		})
+		return
+	}
+
+	key := hostKey(host, port)
+	if _, exists := d.exact[key]; exists {
+		panic(fmt.Sprintf("vhost: duplicate host pattern %q", pattern))
+	}
+	d.exact[key] = h
+}
+
+func (d *Dispatcher) ServeHTTP(w *response.Writer, req *request.Request) {
+	if h := d.match(req.Host); h != nil {
+		h(w, req)
+		return
+	}
+	if d.fallback != nil {
+		d.fallback(w, req)
+		return
+	}
+	_ = w.WriteStatusLine(response.StatusNotFound)
+	_ = w.WriteHeaders(response.GetDefaultHeaders(len(notFoundBody)))
+	_, _ = w.WriteBody([]byte(notFoundBody))
+}
+
+// match prefers an exact host and port, then the exact host on any port,
+// then the most specific wildcard.
+func (d *Dispatcher) match(authority string) server.Handler {
+	host, port, _ := request.SplitHostPort(authority)
+	host = normalizeHost(host)
+	if host == "" {
+		return nil
+	}
+	if port != "" {
+		if h, ok := d.exact[hostKey(host, port)]; ok {
+			return h
+		}
+	}
+	if h, ok := d.exact[host]; ok {
+		return h
+	}
+	for _, w := range d.wildcards {
+		if len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) && (w.port == "" || w.port == port) {
+			return w.handler
+		}
+	}
+	return nil
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), labelSeparator)
+}
+
+func hostKey(host, port string) string {
+	if port == "" {
+		return host
+	}
+	return host + portSeparator + port
+}
@@ -0,0 +1,86 @@
+package vhost
+
+import (
+	"bytes"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func site(name string) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.GetDefaultHeaders(len(name)))
+		_, _ = w.WriteBody([]byte(name))
+	}
+}
+
+func serve(t *testing.T, d *Dispatcher, target, host string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader("GET " + target + " HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	d.ServeHTTP(response.NewWriter(&buf), req)
+	_, body, _ := strings.Cut(buf.String(), "\r\n\r\n")
+	return body
+}
+
+func TestDispatcher(t *testing.T) {
+	d := New(WithFallback(site("fallback")))
+	d.Handle("example.test", site("apex"))
+	d.Handle("example.test:8443", site("apex-8443"))
+	d.Handle("*.example.test", site("any-sub"))
+	d.Handle("*.api.example.test", site("api-sub"))
+	d.Handle("*.example.test:9000", site("sub-9000"))
+	d.Handle("[::1]:8080", site("loopback"))
+
+	tests := []struct {
+		name     string
+		target   string
+		host     string
+		expected string
+	}{
+		{"exact host", "/", "example.test", "apex"},
+		{"exact host any port", "/", "example.test:80", "apex"},
+		{"exact host and port", "/", "example.test:8443", "apex-8443"},
+		{"case and trailing dot", "/", "EXAMPLE.test.", "apex"},
+		{"wildcard", "/", "www.example.test", "any-sub"},
+		{"wildcard multiple labels", "/", "a.b.example.test", "any-sub"},
+		{"longest wildcard", "/", "v1.api.example.test", "api-sub"},
+		{"wildcard with port", "/", "www.example.test:9000", "sub-9000"},
+		{"wildcard other port", "/", "www.example.test:9001", "any-sub"},
+		{"ipv6 literal", "/", "[::1]:8080", "loopback"},
+		{"unknown host", "/", "other.test", "fallback"},
+		{"suffix is not a subdomain", "/", "badexample.test", "fallback"},
+		{"empty host", "/", "", "fallback"},
+		{"absolute-form target wins", "http://www.example.test/", "other.test", "any-sub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, serve(t, d, tt.target, tt.host))
+		})
+	}
+}
+
+func TestDispatcherNotFound(t *testing.T) {
+	d := New()
+	d.Handle("example.test", site("apex"))
+	assert.Equal(t, notFoundBody, serve(t, d, "/", "other.test"))
+}
+
+func TestDispatcherInvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "*", "a.*.test", "example.test:1:2"} {
+		assert.Panics(t, func() { New().Handle(pattern, site("")) }, pattern)
+	}
+
+	d := New()
+	d.Handle("*.example.test", site(""))
+	assert.Panics(t, func() { d.Handle("*.Example.test", site("")) })
+	d.Handle("example.test", site(""))
+	assert.Panics(t, func() { d.Handle("example.test.", site("")) })
+}